@@ -0,0 +1,155 @@
+package access
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Table operations that can carry an access rule.
+const (
+	OpGet    = "get"
+	OpPut    = "put"
+	OpPatch  = "patch"
+	OpDelete = "delete"
+	OpList   = "list"
+	OpScan   = "scan"
+	OpIndex  = "index" // index query, scan, and get, with the index name
+)
+
+// DefaultRolesClaim is the claim holding the caller's roles when
+// server.jwt.rolesClaim is not set.
+const DefaultRolesClaim = "roles"
+
+// RuleFor returns the rule guarding a table operation, or nil when the
+// operation is open. index names the index for OpIndex and is otherwise empty.
+func RuleFor(a *config.AccessConfig, op, index string) *config.AccessRule {
+	if a == nil {
+		return nil
+	}
+
+	var rule *config.AccessRule
+	switch op {
+	case OpGet:
+		rule = a.Get
+	case OpPut:
+		rule = a.Put
+	case OpPatch:
+		rule = a.Patch
+	case OpDelete:
+		rule = a.Delete
+	case OpList:
+		rule = a.List
+	case OpScan:
+		rule = a.Scan
+	case OpIndex:
+		rule = a.Indexes[index]
+	}
+	if rule == nil {
+		return a.Default
+	}
+	return rule
+}
+
+// Check reports why the claims do not satisfy the rule, or nil when they do.
+// The roles are read from rolesClaim, which may be a dotted path such as
+// realm_access.roles.
+func Check(rule *config.AccessRule, claims jwt.MapClaims, rolesClaim string) error {
+	if rule == nil {
+		return nil
+	}
+
+	scopes := Scopes(claims)
+	for _, scope := range rule.Scopes {
+		if !slices.Contains(scopes, scope) {
+			return fmt.Errorf("missing scope %q", scope)
+		}
+	}
+
+	if len(rule.Roles) > 0 {
+		if rolesClaim == "" {
+			rolesClaim = DefaultRolesClaim
+		}
+		roles := ClaimValues(claims, rolesClaim)
+		if !slices.ContainsFunc(rule.Roles, func(role string) bool { return slices.Contains(roles, role) }) {
+			return fmt.Errorf("requires one of roles %s", strings.Join(rule.Roles, ", "))
+		}
+	}
+
+	names := make([]string, 0, len(rule.Claims))
+	for name := range rule.Claims {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if !slices.Contains(ClaimValues(claims, name), rule.Claims[name]) {
+			return fmt.Errorf("claim %q must be %q", name, rule.Claims[name])
+		}
+	}
+	return nil
+}
+
+// HasScope reports whether the token grants the scope.
+func HasScope(claims jwt.MapClaims, scope string) bool {
+	return slices.Contains(Scopes(claims), scope)
+}
+
+// Scopes returns the OAuth scopes granted by the token, read from the
+// space-delimited scope claim or the scp claim (string or array).
+func Scopes(claims jwt.MapClaims) []string {
+	var scopes []string
+	for _, name := range []string{"scope", "scp"} {
+		for _, v := range ClaimValues(claims, name) {
+			scopes = append(scopes, strings.Fields(v)...)
+		}
+	}
+	return scopes
+}
+
+// ClaimValues returns the string values of a claim. path may name a nested
+// claim with dots. Array claims yield each string element; numbers and
+// booleans are formatted.
+func ClaimValues(claims jwt.MapClaims, path string) []string {
+	var v any = map[string]any(claims)
+	for part := range strings.SplitSeq(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		out := make([]string, 0, len(val))
+		for _, e := range val {
+			if s, ok := scalarString(e); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case []string:
+		return val
+	default:
+		if s, ok := scalarString(val); ok {
+			return []string{s}
+		}
+		return nil
+	}
+}
+
+func scalarString(v any) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return val, true
+	case float64, bool, int, int64:
+		return fmt.Sprint(val), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,86 @@
+package access
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRuleFor(t *testing.T) {
+	read := &config.AccessRule{Scopes: []string{"items:read"}}
+	admin := &config.AccessRule{Roles: []string{"admin"}}
+	byStatus := &config.AccessRule{Scopes: []string{"items:status"}}
+	a := &config.AccessConfig{
+		Default: read,
+		Delete:  admin,
+		Indexes: map[string]*config.AccessRule{"by_status": byStatus},
+	}
+
+	tests := []struct {
+		op, index string
+		want      *config.AccessRule
+	}{
+		{OpGet, "", read},
+		{OpDelete, "", admin},
+		{OpIndex, "by_status", byStatus},
+		{OpIndex, "by_owner", read},
+	}
+	for _, tt := range tests {
+		if got := RuleFor(a, tt.op, tt.index); got != tt.want {
+			t.Errorf("RuleFor(%q, %q): expected %#v, got %#v", tt.op, tt.index, tt.want, got)
+		}
+	}
+
+	if got := RuleFor(nil, OpGet, ""); got != nil {
+		t.Errorf("expected nil rule without access config, got %#v", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	claims := jwt.MapClaims{
+		"scope":        "items:read items:write",
+		"realm_access": map[string]any{"roles": []any{"editor"}},
+		"tier":         "gold",
+		"groups":       []any{"eu", "beta"},
+	}
+
+	tests := []struct {
+		name    string
+		rule    *config.AccessRule
+		wantErr string
+	}{
+		{name: "scopes", rule: &config.AccessRule{Scopes: []string{"items:read", "items:write"}}},
+		{name: "missing scope", rule: &config.AccessRule{Scopes: []string{"items:delete"}}, wantErr: `missing scope "items:delete"`},
+		{name: "nested role", rule: &config.AccessRule{Roles: []string{"admin", "editor"}}},
+		{name: "missing role", rule: &config.AccessRule{Roles: []string{"admin"}}, wantErr: "requires one of roles admin"},
+		{name: "claim value", rule: &config.AccessRule{Claims: map[string]string{"tier": "gold", "groups": "beta"}}},
+		{name: "claim mismatch", rule: &config.AccessRule{Claims: map[string]string{"tier": "silver"}}, wantErr: `claim "tier" must be "silver"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.rule, claims, "realm_access.roles")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestScopesFromScpArray(t *testing.T) {
+	claims := jwt.MapClaims{"scp": []any{"items:read", "items:write"}}
+	if !HasScope(claims, "items:write") {
+		t.Fatal("expected items:write scope from scp claim")
+	}
+	if HasScope(claims, "items:delete") {
+		t.Fatal("unexpected items:delete scope")
+	}
+}
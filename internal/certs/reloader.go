@@ -0,0 +1,159 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader serves a TLS certificate and client CA pool loaded from files and
+// reloads them when the files change, so rotated certificates are picked up
+// without a restart.
+type Reloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+// NewReloader loads the certificate, key, and optional client CA bundle.
+func NewReloader(certFile, keyFile, clientCAFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns a server TLS configuration that always uses the most
+// recently loaded certificate and client CA pool.
+func (r *Reloader) TLSConfig(clientAuth tls.ClientAuthType) *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: clientAuth,
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.Certificates = []tls.Certificate{*r.cert}
+		cfg.ClientCAs = r.clientCA
+		return cfg, nil
+	}
+	return base
+}
+
+// Run checks the files every interval until ctx is done and reloads them
+// when any has changed. A failed reload keeps the previous certificate.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.changed()
+			if err != nil {
+				slog.Warn("checking TLS certificate files failed", "error", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			if err := r.load(); err != nil {
+				slog.Error("reloading TLS certificate failed, keeping the previous one", "error", err)
+				continue
+			}
+			slog.Info("reloaded TLS certificate", "file", r.certFile)
+		}
+	}
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+	return files
+}
+
+// changed reports whether any file's modification time differs from when it
+// was last loaded.
+func (r *Reloader) changed() (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return false, err
+		}
+		if !info.ModTime().Equal(r.modTimes[f]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *Reloader) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		modTimes[f] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("reading client CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("client CA file %s contains no certificates", r.clientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCA = pool
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+// ClientAuthType maps a server.tls.clientAuth value (none, optional, or
+// require) to its tls.ClientAuthType. Optional certificates are still
+// verified against the client CA when presented.
+func ClientAuthType(mode string) tls.ClientAuthType {
+	switch mode {
+	case "optional":
+		return tls.VerifyClientCertIfGiven
+	case "require":
+		return tls.RequireAndVerifyClientCert
+	default:
+		return tls.NoClientCert
+	}
+}
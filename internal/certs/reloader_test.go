@@ -0,0 +1,112 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned writes a self-signed certificate and key for cn and
+// returns their paths.
+func writeSelfSigned(t *testing.T, dir, cn string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshaling key: %v", err)
+	}
+
+	certPath := filepath.Join(dir, "tls.crt")
+	keyPath := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("writing certificate: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("writing key: %v", err)
+	}
+	return certPath, keyPath
+}
+
+func servedCommonName(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	clientCfg, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("GetConfigForClient: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(clientCfg.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("parsing served certificate: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestReloader_PicksUpRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeSelfSigned(t, dir, "first")
+
+	r, err := NewReloader(certPath, keyPath, "")
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	cfg := r.TLSConfig(tls.NoClientCert)
+	if got := servedCommonName(t, cfg); got != "first" {
+		t.Fatalf("expected first certificate, got %q", got)
+	}
+
+	writeSelfSigned(t, dir, "second")
+	later := time.Now().Add(time.Minute)
+	for _, f := range []string{certPath, keyPath} {
+		if err := os.Chtimes(f, later, later); err != nil {
+			t.Fatalf("touching %s: %v", f, err)
+		}
+	}
+
+	changed, err := r.changed()
+	if err != nil || !changed {
+		t.Fatalf("expected a change to be detected, got %v, %v", changed, err)
+	}
+	if err := r.load(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := servedCommonName(t, cfg); got != "second" {
+		t.Fatalf("expected second certificate after reload, got %q", got)
+	}
+}
+
+func TestReloader_RejectsEmptyClientCA(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeSelfSigned(t, dir, "server")
+	caPath := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caPath, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("writing CA file: %v", err)
+	}
+
+	if _, err := NewReloader(certPath, keyPath, caPath); err == nil {
+		t.Fatal("expected an error for a CA file without certificates")
+	}
+}
@@ -2,8 +2,12 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/schema"
 	"gopkg.in/yaml.v3"
@@ -12,24 +16,168 @@ import (
 var (
 	nameRegexp     = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
 	keyFieldRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+	tenantRegexp   = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	apiKeyHashRe   = regexp.MustCompile(`^[0-9a-f]{64}$`)
 )
 
+// maxSchemaNameLength is PostgreSQL's identifier length limit (NAMEDATALEN - 1).
+const maxSchemaNameLength = 63
+
 type Config struct {
-	Server ServerConfig  `yaml:"server"`
-	Tables []TableConfig `yaml:"tables"`
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Logging  LoggingConfig  `yaml:"logging"`
+	Tables   []TableConfig  `yaml:"tables"`
+}
+
+// LoggingConfig sets the level (debug, info, warn, or error; default info)
+// and format (text or json; default text) of log output.
+type LoggingConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+	// SlowQueryThreshold logs database queries taking at least this long,
+	// such as "250ms". Zero disables the log.
+	SlowQueryThreshold time.Duration `yaml:"slowQueryThreshold"`
+}
+
+type DatabaseConfig struct {
+	Schema      string `yaml:"schema"`
+	TenantClaim string `yaml:"tenantClaim"`
 }
 
 type ServerConfig struct {
 	Port    int           `yaml:"port"`
 	JWT     JWTConfig     `yaml:"jwt"`
+	APIKeys APIKeysConfig `yaml:"apiKeys"`
+	TLS     TLSConfig     `yaml:"tls"`
 	Swagger SwaggerConfig `yaml:"swagger"`
+	Admin   AdminConfig   `yaml:"admin"`
+	Tracing TracingConfig `yaml:"tracing"`
+
+	RateLimit ServerRateLimitConfig `yaml:"rateLimit"`
+	// MaxPageSize caps the limit query parameter of list endpoints; larger
+	// values are lowered to it. Defaults to DefaultMaxPageSize.
+	MaxPageSize int `yaml:"maxPageSize"`
+}
+
+// DefaultMaxPageSize is the largest page a list request returns when
+// server.maxPageSize is not set.
+const DefaultMaxPageSize = 1000
+
+// ServerRateLimitConfig limits requests across all tables. Clients are
+// identified by their sub claim, or by remote address when unauthenticated.
+type ServerRateLimitConfig struct {
+	// Global limits all clients together.
+	Global *RateLimitConfig `yaml:"global"`
+	// PerClient limits each client.
+	PerClient *RateLimitConfig `yaml:"perClient"`
+	// Clients replaces PerClient for the listed sub values.
+	Clients map[string]*RateLimitConfig `yaml:"clients"`
+	// MaxConcurrentScans caps in-flight table and index scans; zero is
+	// unlimited.
+	MaxConcurrentScans int `yaml:"maxConcurrentScans"`
+}
+
+// RateLimitConfig is a token bucket of Burst requests refilled at
+// RequestsPerSecond. Burst defaults to RequestsPerSecond rounded up.
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requestsPerSecond"`
+	Burst             int     `yaml:"burst"`
+}
+
+// AdminConfig guards the /_admin endpoints, which are only served when Access
+// is set, and configures the admin listener.
+type AdminConfig struct {
+	Access *AccessRule `yaml:"access"`
+	// Explain lets callers satisfying Access add explain=true to list
+	// requests to get the query plan instead of items.
+	Explain bool `yaml:"explain"`
+	// Port serves /metrics, without authentication, on a separate listener
+	// when set. It must not be reachable from outside the deployment.
+	Port int `yaml:"port"`
+}
+
+// TracingConfig exports OpenTelemetry spans for API requests and database
+// queries. Exporter is otlp, stdout, or file; without one tracing is off.
+type TracingConfig struct {
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the OTLP/HTTP collector base URL; spans are posted to
+	// {endpoint}/v1/traces. Defaults to DefaultTracingEndpoint.
+	Endpoint string            `yaml:"endpoint"`
+	Headers  map[string]string `yaml:"headers"` // sent with every OTLP export
+	File     string            `yaml:"file"`    // spans are appended here by the file exporter
+	// ServiceName is the service.name resource attribute; defaults to
+	// itemservicecentral.
+	ServiceName string `yaml:"serviceName"`
+	// SampleRatio is the share of new traces that are recorded; defaults to
+	// 1. Requests with a traceparent follow the caller's sampling decision.
+	SampleRatio *float64 `yaml:"sampleRatio"`
+}
+
+// DefaultTracingEndpoint is the OTLP/HTTP endpoint used when
+// server.tracing.endpoint is not set.
+const DefaultTracingEndpoint = "http://localhost:4318"
+
+// AuthEnabled reports whether requests must authenticate with a JWT, an API
+// key, or a client certificate.
+func (s ServerConfig) AuthEnabled() bool {
+	return s.JWT.Enabled || s.APIKeys.Enabled || s.TLS.ClientCertIdentity
+}
+
+// TLSConfig makes the API listener serve HTTPS. The certificate, key, and
+// client CA files are reloaded when they change.
+type TLSConfig struct {
+	CertFile     string `yaml:"certFile"`
+	KeyFile      string `yaml:"keyFile"`
+	ClientCAFile string `yaml:"clientCAFile"`
+	// ClientAuth is none, optional, or require; it defaults to require when
+	// ClientCAFile is set.
+	ClientAuth string `yaml:"clientAuth"`
+	// ClientCertIdentity accepts a verified client certificate as the
+	// caller's identity in place of a JWT.
+	ClientCertIdentity bool `yaml:"clientCertIdentity"`
+}
+
+// Enabled reports whether the API listener serves HTTPS.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// APIKeysConfig enables X-API-Key authentication. Keys are listed here by
+// hash, stored in the _api_keys table, or both.
+type APIKeysConfig struct {
+	Enabled bool           `yaml:"enabled"`
+	Table   bool           `yaml:"table"`
+	Keys    []APIKeyConfig `yaml:"keys"`
+}
+
+// APIKeyConfig is an API key listed in the config. Hash is the hex SHA-256 of
+// the key; Claims are added to the claims the key authenticates with.
+type APIKeyConfig struct {
+	Name   string            `yaml:"name"`
+	Hash   string            `yaml:"hash"`
+	Scopes []string          `yaml:"scopes"`
+	Claims map[string]string `yaml:"claims"`
 }
 
 type JWTConfig struct {
-	Enabled  bool   `yaml:"enabled"`
-	JWKSUrl  string `yaml:"jwksUrl"`
-	Issuer   string `yaml:"issuer"`
-	Audience string `yaml:"audience"`
+	Enabled    bool   `yaml:"enabled"`
+	JWKSUrl    string `yaml:"jwksUrl"`
+	Issuer     string `yaml:"issuer"`
+	Audience   string `yaml:"audience"`
+	RolesClaim string `yaml:"rolesClaim"`
+	// Algorithms lists the accepted signing algorithms; defaults to RS256.
+	// HS256 verifies with the shared secret in JWT_HMAC_SECRET.
+	Algorithms []string `yaml:"algorithms"`
+}
+
+// SupportedJWTAlgorithms are the values allowed in server.jwt.algorithms.
+var SupportedJWTAlgorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+	"HS256",
 }
 
 type SwaggerConfig struct {
@@ -37,12 +185,57 @@ type SwaggerConfig struct {
 }
 
 type TableConfig struct {
-	Name           string        `yaml:"name"`
-	PrimaryKey     KeyConfig     `yaml:"primaryKey"`
-	RangeKey       *KeyConfig    `yaml:"rangeKey"`
-	AllowTableScan bool          `yaml:"allowTableScan"`
-	Schema         any           `yaml:"schema"`
-	Indexes        []IndexConfig `yaml:"indexes"`
+	Name           string           `yaml:"name"`
+	PrimaryKey     KeyConfig        `yaml:"primaryKey"`
+	RangeKey       *KeyConfig       `yaml:"rangeKey"`
+	AllowTableScan bool             `yaml:"allowTableScan"`
+	TenantClaim    string           `yaml:"tenantClaim"`
+	OwnerField     string           `yaml:"ownerField"`
+	OwnerClaim     string           `yaml:"ownerClaim"`
+	Access         *AccessConfig    `yaml:"access"`
+	Audit          *AuditConfig     `yaml:"audit"`
+	RateLimit      *RateLimitConfig `yaml:"rateLimit"` // per client, in addition to the server limits
+	Schema         any              `yaml:"schema"`
+	Indexes        []IndexConfig    `yaml:"indexes"`
+}
+
+// AuditConfig records every PUT, PATCH, and DELETE on a table. Sink is
+// "table" (the _audit table, the default) or "log"; Diff adds the item data
+// before and after each write.
+type AuditConfig struct {
+	Sink string `yaml:"sink"`
+	Diff bool   `yaml:"diff"`
+}
+
+// AccessConfig maps table operations to the claims a caller needs. Operations
+// without a rule fall back to Default; with no Default they are open to any
+// authenticated caller.
+type AccessConfig struct {
+	Default *AccessRule             `yaml:"default"`
+	Get     *AccessRule             `yaml:"get"`
+	Put     *AccessRule             `yaml:"put"`
+	Patch   *AccessRule             `yaml:"patch"`
+	Delete  *AccessRule             `yaml:"delete"`
+	List    *AccessRule             `yaml:"list"`
+	Scan    *AccessRule             `yaml:"scan"`
+	Indexes map[string]*AccessRule  `yaml:"indexes"`
+	Fields  map[string]*FieldAccess `yaml:"fields"`
+}
+
+// FieldAccess guards one top-level item attribute. Callers that do not
+// satisfy Read never see the attribute; callers that do not satisfy Write
+// cannot set or change it.
+type FieldAccess struct {
+	Read  *AccessRule `yaml:"read"`
+	Write *AccessRule `yaml:"write"`
+}
+
+// AccessRule is satisfied when the token has every scope, at least one of the
+// roles, and every claim value listed.
+type AccessRule struct {
+	Scopes []string          `yaml:"scopes"`
+	Roles  []string          `yaml:"roles"`
+	Claims map[string]string `yaml:"claims"`
 }
 
 type KeyConfig struct {
@@ -63,6 +256,19 @@ type IndexConfig struct {
 	AllowIndexScan bool             `yaml:"allowIndexScan"`
 }
 
+// TenantSchema returns the PostgreSQL schema that holds a tenant's tables in
+// schema-per-tenant mode: the configured schema, an underscore, and the tenant.
+func (d DatabaseConfig) TenantSchema(tenant string) (string, error) {
+	if !tenantRegexp.MatchString(tenant) {
+		return "", fmt.Errorf("tenant %q must match %s", tenant, tenantRegexp.String())
+	}
+	schema := d.Schema + "_" + tenant
+	if len(schema) > maxSchemaNameLength {
+		return "", fmt.Errorf("tenant %q: schema name %q exceeds %d characters", tenant, schema, maxSchemaNameLength)
+	}
+	return schema, nil
+}
+
 // Load reads and parses a YAML configuration file from the given path.
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -85,6 +291,38 @@ func Validate(cfg *Config) error {
 		cfg.Server.Port = 8080
 	}
 
+	if err := validateJWT(cfg.Server.JWT); err != nil {
+		return err
+	}
+
+	if err := validateAPIKeys(cfg.Server.APIKeys); err != nil {
+		return err
+	}
+
+	if err := validateTLS(&cfg.Server.TLS); err != nil {
+		return err
+	}
+
+	if err := validateAdmin(cfg.Server); err != nil {
+		return err
+	}
+
+	if err := validateServerRateLimit(&cfg.Server); err != nil {
+		return err
+	}
+
+	if err := validateTracing(&cfg.Server.Tracing); err != nil {
+		return err
+	}
+
+	if err := validateDatabase(cfg); err != nil {
+		return err
+	}
+
+	if err := validateLogging(&cfg.Logging); err != nil {
+		return err
+	}
+
 	if len(cfg.Tables) == 0 {
 		return fmt.Errorf("at least one table must be defined")
 	}
@@ -130,6 +368,10 @@ func Validate(cfg *Config) error {
 			}
 		}
 
+		if t.TenantClaim != "" && !cfg.Server.AuthEnabled() {
+			return fmt.Errorf("table %q: tenantClaim requires server.jwt.enabled, server.apiKeys.enabled, or server.tls.clientCertIdentity", t.Name)
+		}
+
 		// Schema validation
 		if t.Schema == nil {
 			return fmt.Errorf("table %q: schema is required", t.Name)
@@ -142,6 +384,22 @@ func Validate(cfg *Config) error {
 			return err
 		}
 
+		if err := validateAccess(t, cfg.Server.AuthEnabled()); err != nil {
+			return err
+		}
+
+		if err := validateOwner(t, cfg.Server.AuthEnabled()); err != nil {
+			return err
+		}
+
+		if err := validateAudit(t.Audit); err != nil {
+			return fmt.Errorf("table %q: %w", t.Name, err)
+		}
+
+		if err := validateRateLimit("rateLimit", t.RateLimit); err != nil {
+			return fmt.Errorf("table %q: %w", t.Name, err)
+		}
+
 		// Index validation
 		indexNames := make(map[string]bool)
 		for j, idx := range t.Indexes {
@@ -210,6 +468,373 @@ func Validate(cfg *Config) error {
 	return nil
 }
 
+func validateJWT(j JWTConfig) error {
+	seen := make(map[string]bool, len(j.Algorithms))
+	for _, alg := range j.Algorithms {
+		if !slices.Contains(SupportedJWTAlgorithms, alg) {
+			return fmt.Errorf("server.jwt: algorithm %q must be one of %s", alg, strings.Join(SupportedJWTAlgorithms, ", "))
+		}
+		if seen[alg] {
+			return fmt.Errorf("server.jwt: duplicate algorithm %q", alg)
+		}
+		seen[alg] = true
+	}
+	if !j.Enabled {
+		return nil
+	}
+
+	hmacOnly := len(j.Algorithms) > 0 && !slices.ContainsFunc(j.Algorithms, func(alg string) bool { return alg != "HS256" })
+	if j.JWKSUrl == "" && j.Issuer == "" && !hmacOnly {
+		return fmt.Errorf("server.jwt: jwksUrl or issuer is required unless algorithms is [HS256]")
+	}
+	return nil
+}
+
+func validateAPIKeys(a APIKeysConfig) error {
+	if !a.Enabled {
+		if a.Table || len(a.Keys) > 0 {
+			return fmt.Errorf("server.apiKeys: keys and table require enabled")
+		}
+		return nil
+	}
+	if !a.Table && len(a.Keys) == 0 {
+		return fmt.Errorf("server.apiKeys: list keys or set table")
+	}
+
+	names := make(map[string]bool, len(a.Keys))
+	hashes := make(map[string]bool, len(a.Keys))
+	for i, k := range a.Keys {
+		if k.Name == "" {
+			return fmt.Errorf("server.apiKeys.keys[%d]: name is required", i)
+		}
+		if names[k.Name] {
+			return fmt.Errorf("server.apiKeys.keys[%d]: duplicate name %q", i, k.Name)
+		}
+		names[k.Name] = true
+		if !apiKeyHashRe.MatchString(k.Hash) {
+			return fmt.Errorf("server.apiKeys.keys[%d]: hash must be the lowercase hex SHA-256 of the key", i)
+		}
+		if hashes[k.Hash] {
+			return fmt.Errorf("server.apiKeys.keys[%d]: duplicate hash", i)
+		}
+		hashes[k.Hash] = true
+		for claim := range k.Claims {
+			if claim == "" {
+				return fmt.Errorf("server.apiKeys.keys[%d]: claim name must not be empty", i)
+			}
+			if claim == "scope" || claim == "scp" {
+				return fmt.Errorf("server.apiKeys.keys[%d]: claim %q is not allowed; use scopes", i, claim)
+			}
+		}
+	}
+	return nil
+}
+
+func validateTLS(t *TLSConfig) error {
+	if !t.Enabled() {
+		if t.KeyFile != "" || t.ClientCAFile != "" || t.ClientAuth != "" || t.ClientCertIdentity {
+			return fmt.Errorf("server.tls: certFile is required")
+		}
+		return nil
+	}
+	if t.KeyFile == "" {
+		return fmt.Errorf("server.tls: keyFile is required with certFile")
+	}
+
+	if t.ClientAuth == "" {
+		t.ClientAuth = "none"
+		if t.ClientCAFile != "" {
+			t.ClientAuth = "require"
+		}
+	}
+	switch t.ClientAuth {
+	case "none":
+		if t.ClientCAFile != "" {
+			return fmt.Errorf("server.tls: clientCAFile requires clientAuth optional or require")
+		}
+	case "optional", "require":
+		if t.ClientCAFile == "" {
+			return fmt.Errorf("server.tls: clientAuth %s requires clientCAFile", t.ClientAuth)
+		}
+	default:
+		return fmt.Errorf("server.tls: clientAuth must be one of none, optional, or require")
+	}
+
+	if t.ClientCertIdentity && t.ClientAuth == "none" {
+		return fmt.Errorf("server.tls: clientCertIdentity requires clientCAFile")
+	}
+	return nil
+}
+
+func validateDatabase(cfg *Config) error {
+	d := cfg.Database
+	if d.Schema != "" {
+		if !nameRegexp.MatchString(d.Schema) {
+			return fmt.Errorf("database: schema %q must match %s", d.Schema, nameRegexp.String())
+		}
+		if strings.HasPrefix(d.Schema, "pg_") {
+			return fmt.Errorf("database: schema %q must not start with \"pg_\"", d.Schema)
+		}
+		if len(d.Schema) > maxSchemaNameLength {
+			return fmt.Errorf("database: schema %q exceeds %d characters", d.Schema, maxSchemaNameLength)
+		}
+	}
+	if d.TenantClaim != "" {
+		if d.Schema == "" {
+			return fmt.Errorf("database: schema is required when tenantClaim is set; it is used as the tenant schema prefix")
+		}
+		if !cfg.Server.AuthEnabled() {
+			return fmt.Errorf("database: tenantClaim requires server.jwt.enabled, server.apiKeys.enabled, or server.tls.clientCertIdentity")
+		}
+	}
+	return nil
+}
+
+func validateAccess(t TableConfig, authEnabled bool) error {
+	if t.Access == nil {
+		return nil
+	}
+	if !authEnabled {
+		return fmt.Errorf("table %q: access requires server.jwt.enabled, server.apiKeys.enabled, or server.tls.clientCertIdentity", t.Name)
+	}
+
+	a := t.Access
+	rules := map[string]*AccessRule{
+		"default": a.Default,
+		"get":     a.Get,
+		"put":     a.Put,
+		"patch":   a.Patch,
+		"delete":  a.Delete,
+		"list":    a.List,
+		"scan":    a.Scan,
+	}
+	for name, rule := range a.Indexes {
+		if !slices.ContainsFunc(t.Indexes, func(idx IndexConfig) bool { return idx.Name == name }) {
+			return fmt.Errorf("table %q: access: index %q is not defined", t.Name, name)
+		}
+		rules["indexes."+name] = rule
+	}
+	for field, fa := range a.Fields {
+		if err := validateFieldAccess(t, field, fa); err != nil {
+			return err
+		}
+		if fa.Read != nil {
+			rules["fields."+field+".read"] = fa.Read
+		}
+		if fa.Write != nil {
+			rules["fields."+field+".write"] = fa.Write
+		}
+	}
+	for op, rule := range rules {
+		if rule == nil {
+			continue
+		}
+		if err := validateAccessRule("access."+op, rule); err != nil {
+			return fmt.Errorf("table %q: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateAccessRule checks that the rule at path requires something of the
+// caller.
+func validateAccessRule(path string, rule *AccessRule) error {
+	if len(rule.Scopes) == 0 && len(rule.Roles) == 0 && len(rule.Claims) == 0 {
+		return fmt.Errorf("%s must list scopes, roles, or claims", path)
+	}
+	for claim := range rule.Claims {
+		if claim == "" {
+			return fmt.Errorf("%s: claim name must not be empty", path)
+		}
+	}
+	return nil
+}
+
+func validateAdmin(s ServerConfig) error {
+	if s.Admin.Port < 0 || s.Admin.Port > 65535 {
+		return fmt.Errorf("server.admin.port must be between 1 and 65535")
+	}
+	if s.Admin.Port != 0 && s.Admin.Port == s.Port {
+		return fmt.Errorf("server.admin.port must differ from server.port")
+	}
+	if s.Admin.Access == nil {
+		if s.Admin.Explain {
+			return fmt.Errorf("server.admin.explain requires server.admin.access")
+		}
+		return nil
+	}
+	if !s.AuthEnabled() {
+		return fmt.Errorf("server.admin.access requires server.jwt.enabled, server.apiKeys.enabled, or server.tls.clientCertIdentity")
+	}
+	return validateAccessRule("server.admin.access", s.Admin.Access)
+}
+
+func validateLogging(l *LoggingConfig) error {
+	if l.Level == "" {
+		l.Level = "info"
+	}
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, l.Level) {
+		return fmt.Errorf("logging.level must be one of debug, info, warn, or error")
+	}
+	if l.Format == "" {
+		l.Format = "text"
+	}
+	if l.Format != "text" && l.Format != "json" {
+		return fmt.Errorf("logging.format must be text or json")
+	}
+	if l.SlowQueryThreshold < 0 {
+		return fmt.Errorf("logging.slowQueryThreshold must not be negative")
+	}
+	return nil
+}
+
+func validateTracing(t *TracingConfig) error {
+	switch t.Exporter {
+	case "":
+		if t.Endpoint != "" || len(t.Headers) > 0 || t.File != "" || t.ServiceName != "" || t.SampleRatio != nil {
+			return fmt.Errorf("server.tracing: exporter is required")
+		}
+		return nil
+	case "otlp":
+		if t.Endpoint == "" {
+			t.Endpoint = DefaultTracingEndpoint
+		}
+		if !strings.HasPrefix(t.Endpoint, "http://") && !strings.HasPrefix(t.Endpoint, "https://") {
+			return fmt.Errorf("server.tracing.endpoint must be an http or https URL")
+		}
+	case "stdout":
+	case "file":
+		if t.File == "" {
+			return fmt.Errorf("server.tracing: exporter file requires file")
+		}
+	default:
+		return fmt.Errorf("server.tracing.exporter must be one of otlp, stdout, or file")
+	}
+	if t.Exporter != "otlp" && (t.Endpoint != "" || len(t.Headers) > 0) {
+		return fmt.Errorf("server.tracing: endpoint and headers require exporter otlp")
+	}
+	if t.Exporter != "file" && t.File != "" {
+		return fmt.Errorf("server.tracing: file requires exporter file")
+	}
+
+	if t.ServiceName == "" {
+		t.ServiceName = "itemservicecentral"
+	}
+	if t.SampleRatio == nil {
+		ratio := 1.0
+		t.SampleRatio = &ratio
+	} else if *t.SampleRatio < 0 || *t.SampleRatio > 1 {
+		return fmt.Errorf("server.tracing.sampleRatio must be between 0 and 1")
+	}
+	return nil
+}
+
+func validateServerRateLimit(s *ServerConfig) error {
+	if s.MaxPageSize < 0 {
+		return fmt.Errorf("server.maxPageSize must not be negative")
+	}
+	if s.MaxPageSize == 0 {
+		s.MaxPageSize = DefaultMaxPageSize
+	}
+
+	rl := s.RateLimit
+	if rl.MaxConcurrentScans < 0 {
+		return fmt.Errorf("server.rateLimit.maxConcurrentScans must not be negative")
+	}
+	if err := validateRateLimit("server.rateLimit.global", rl.Global); err != nil {
+		return err
+	}
+	if err := validateRateLimit("server.rateLimit.perClient", rl.PerClient); err != nil {
+		return err
+	}
+	for sub, c := range rl.Clients {
+		if sub == "" {
+			return fmt.Errorf("server.rateLimit.clients: sub must not be empty")
+		}
+		if c == nil {
+			return fmt.Errorf("server.rateLimit.clients.%s must set requestsPerSecond", sub)
+		}
+		if err := validateRateLimit("server.rateLimit.clients."+sub, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRateLimit checks the rate limit at path and defaults its burst.
+func validateRateLimit(path string, c *RateLimitConfig) error {
+	if c == nil {
+		return nil
+	}
+	if c.RequestsPerSecond <= 0 {
+		return fmt.Errorf("%s.requestsPerSecond must be positive", path)
+	}
+	if c.Burst < 0 {
+		return fmt.Errorf("%s.burst must not be negative", path)
+	}
+	if c.Burst == 0 {
+		c.Burst = int(math.Ceil(c.RequestsPerSecond))
+	}
+	return nil
+}
+
+// validateAudit defaults the audit sink and checks it.
+func validateAudit(a *AuditConfig) error {
+	if a == nil {
+		return nil
+	}
+	switch a.Sink {
+	case "":
+		a.Sink = "table"
+	case "table", "log":
+	default:
+		return fmt.Errorf("audit.sink must be table or log")
+	}
+	return nil
+}
+
+func validateFieldAccess(t TableConfig, field string, fa *FieldAccess) error {
+	if field == t.PrimaryKey.Field || (t.RangeKey != nil && field == t.RangeKey.Field) {
+		return fmt.Errorf("table %q: access.fields: key field %q cannot have field rules", t.Name, field)
+	}
+	if fa == nil || (fa.Read == nil && fa.Write == nil) {
+		return fmt.Errorf("table %q: access.fields.%s must set read or write", t.Name, field)
+	}
+
+	schemaMap, _ := t.Schema.(map[string]any)
+	props, _ := schemaMap["properties"].(map[string]any)
+	if _, ok := props[field]; !ok {
+		return fmt.Errorf("table %q: access.fields: schema does not define property %q", t.Name, field)
+	}
+	return nil
+}
+
+func validateOwner(t TableConfig, authEnabled bool) error {
+	if t.OwnerField == "" {
+		if t.OwnerClaim != "" {
+			return fmt.Errorf("table %q: ownerClaim requires ownerField", t.Name)
+		}
+		return nil
+	}
+	if !authEnabled {
+		return fmt.Errorf("table %q: ownerField requires server.jwt.enabled, server.apiKeys.enabled, or server.tls.clientCertIdentity", t.Name)
+	}
+	if !keyFieldRegexp.MatchString(t.OwnerField) {
+		return fmt.Errorf("table %q: ownerField %q must match %s", t.Name, t.OwnerField, keyFieldRegexp.String())
+	}
+	if t.OwnerField == t.PrimaryKey.Field || (t.RangeKey != nil && t.OwnerField == t.RangeKey.Field) {
+		return fmt.Errorf("table %q: ownerField must not be the primaryKey or rangeKey field", t.Name)
+	}
+
+	schemaMap, _ := t.Schema.(map[string]any)
+	props, _ := schemaMap["properties"].(map[string]any)
+	prop, _ := props[t.OwnerField].(map[string]any)
+	if typeVal, _ := prop["type"].(string); typeVal != "string" {
+		return fmt.Errorf("table %q: schema must define property %q for ownerField with type \"string\"", t.Name, t.OwnerField)
+	}
+	return nil
+}
+
 func validateSchemaKeys(t TableConfig) error {
 	schemaMap, ok := t.Schema.(map[string]any)
 	if !ok {
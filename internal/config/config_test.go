@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 // helper to write a temp YAML file and return its path
@@ -1152,3 +1153,267 @@ tables:
 		t.Errorf("unexpected error: %v", err)
 	}
 }
+
+// validateCase is a config fragment and the error Validate should report
+// for it; an empty wantErr expects a valid config.
+type validateCase struct {
+	name     string
+	server   string // entries under server, indented by two spaces
+	database string // entries under database, indented by two spaces
+	logging  string // entries under logging, indented by two spaces
+	table    string // entries of the items table, indented by four spaces
+	wantErr  string
+	check    func(t *testing.T, cfg *Config) // run on valid configs
+}
+
+// jwtServer enables JWT authentication in a validateCase server fragment.
+const jwtServer = "jwt:\n    enabled: true\n    jwksUrl: https://example.com/.well-known/jwks.json"
+
+// testValidate loads each case's fragments into a config with an items table
+// and checks the result of Validate.
+func testValidate(t *testing.T, cases []validateCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			yaml := `
+server:
+  ` + tc.server + `
+database:
+  ` + tc.database + `
+logging:
+  ` + tc.logging + `
+tables:
+  - name: items
+    ` + tc.table + `
+    primaryKey:
+      field: itemId
+      pattern: "^[a-z]+$"
+    schema:
+      type: object
+      additionalProperties: false
+      properties:
+        itemId:
+          type: string
+          pattern: "^[a-z]+$"
+        status:
+          type: string
+        ownerId:
+          type: string
+    indexes:
+      - name: by_status
+        primaryKey:
+          field: status
+`
+			cfg, err := Load(writeTempConfig(t, yaml))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err = Validate(cfg)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected validation error: %v", err)
+			}
+			if tc.check != nil {
+				tc.check(t, cfg)
+			}
+		})
+	}
+}
+
+func TestValidate_DatabaseTenantClaim(t *testing.T) {
+	testValidate(t, []validateCase{
+		{name: "schema only", database: "schema: inventory"},
+		{name: "tenant claim", server: jwtServer, database: "schema: inventory\n  tenantClaim: org_id"},
+		{name: "invalid schema", database: "schema: Inventory", wantErr: "database: schema"},
+		{name: "reserved schema", database: "schema: pg_items", wantErr: `must not start with "pg_"`},
+		{name: "tenant claim without schema", server: jwtServer, database: "tenantClaim: org_id", wantErr: "schema is required when tenantClaim is set"},
+		{name: "tenant claim without jwt", database: "schema: inventory\n  tenantClaim: org_id", wantErr: "tenantClaim requires server.jwt.enabled"},
+	})
+}
+
+func TestDatabaseConfig_TenantSchema(t *testing.T) {
+	d := DatabaseConfig{Schema: "inventory", TenantClaim: "org_id"}
+
+	schema, err := d.TenantSchema("Acme-01")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if schema != "inventory_Acme-01" {
+		t.Fatalf("expected inventory_Acme-01, got %q", schema)
+	}
+
+	if _, err := d.TenantSchema("acme;drop"); err == nil {
+		t.Fatal("expected error for tenant with invalid characters")
+	}
+	if _, err := d.TenantSchema(strings.Repeat("a", 60)); err == nil {
+		t.Fatal("expected error for schema name over 63 characters")
+	}
+}
+
+func TestValidate_TableTenantClaimRequiresJWT(t *testing.T) {
+	testValidate(t, []validateCase{
+		{name: "without jwt", table: "tenantClaim: org_id", wantErr: "tenantClaim requires server.jwt.enabled"},
+	})
+}
+
+func TestValidate_AccessRules(t *testing.T) {
+	testValidate(t, []validateCase{
+		{name: "valid", server: jwtServer, table: "access:\n      delete:\n        roles: [admin]\n      indexes:\n        by_status:\n          scopes: [items:read]"},
+		{name: "empty rule", server: jwtServer, table: "access:\n      get: {}", wantErr: "access.get must list scopes, roles, or claims"},
+		{name: "unknown index", server: jwtServer, table: "access:\n      indexes:\n        by_owner:\n          scopes: [items:read]", wantErr: `access: index "by_owner" is not defined`},
+		{name: "field rules", server: jwtServer, table: "access:\n      fields:\n        status:\n          read:\n            scopes: [items:status]"},
+		{name: "field rule on key", server: jwtServer, table: "access:\n      fields:\n        itemId:\n          write:\n            roles: [admin]", wantErr: `key field "itemId" cannot have field rules`},
+		{name: "field not in schema", server: jwtServer, table: "access:\n      fields:\n        ssn:\n          read:\n            roles: [admin]", wantErr: `schema does not define property "ssn"`},
+		{name: "empty field rule", server: jwtServer, table: "access:\n      fields:\n        status: {}", wantErr: "access.fields.status must set read or write"},
+	})
+}
+
+func TestValidate_OwnerField(t *testing.T) {
+	testValidate(t, []validateCase{
+		{name: "valid", server: jwtServer, table: "ownerField: ownerId\n    ownerClaim: email"},
+		{name: "requires jwt", table: "ownerField: ownerId", wantErr: "ownerField requires server.jwt.enabled"},
+		{name: "key field", server: jwtServer, table: "ownerField: itemId", wantErr: "ownerField must not be the primaryKey or rangeKey field"},
+		{name: "undefined property", server: jwtServer, table: "ownerField: creator", wantErr: `schema must define property "creator" for ownerField`},
+		{name: "claim without field", server: jwtServer, table: "ownerClaim: email", wantErr: "ownerClaim requires ownerField"},
+	})
+}
+
+func TestValidate_JWTAlgorithms(t *testing.T) {
+	const jwt = "jwt:\n    enabled: true\n    "
+	testValidate(t, []validateCase{
+		{name: "default", server: jwt + "jwksUrl: https://example.com/jwks.json"},
+		{name: "asymmetric", server: jwt + "jwksUrl: https://example.com/jwks.json\n    algorithms: [ES256, EdDSA]"},
+		{name: "hmac only", server: jwt + "algorithms: [HS256]"},
+		{name: "unsupported", server: jwt + "jwksUrl: https://example.com/jwks.json\n    algorithms: [none]", wantErr: `algorithm "none" must be one of`},
+		{name: "duplicate", server: jwt + "jwksUrl: https://example.com/jwks.json\n    algorithms: [ES256, ES256]", wantErr: `duplicate algorithm "ES256"`},
+		{name: "issuer discovery", server: jwt + "issuer: https://auth.example.com\n    algorithms: [ES256]"},
+		{name: "missing jwks", server: jwt + "algorithms: [HS256, ES256]", wantErr: "jwksUrl or issuer is required"},
+	})
+}
+
+func TestValidate_APIKeys(t *testing.T) {
+	const hash = "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+	const access = "access:\n      default:\n        scopes: [items:read]"
+	testValidate(t, []validateCase{
+		{name: "listed key", server: "apiKeys:\n    enabled: true\n    keys:\n      - name: batch\n        hash: " + hash + "\n        scopes: [items:read]", table: access},
+		{name: "table only", server: "apiKeys:\n    enabled: true\n    table: true", table: access},
+		{name: "no keys", server: "apiKeys:\n    enabled: true", table: access, wantErr: "list keys or set table"},
+		{name: "not enabled", server: "apiKeys:\n    table: true", table: access, wantErr: "keys and table require enabled"},
+		{name: "bad hash", server: "apiKeys:\n    enabled: true\n    keys:\n      - name: batch\n        hash: not-a-hash", table: access, wantErr: "hash must be the lowercase hex SHA-256"},
+		{name: "duplicate name", server: "apiKeys:\n    enabled: true\n    keys:\n      - name: batch\n        hash: " + hash + "\n      - name: batch\n        hash: " + strings.Repeat("0", 64), table: access, wantErr: `duplicate name "batch"`},
+		{name: "scope claim", server: "apiKeys:\n    enabled: true\n    keys:\n      - name: batch\n        hash: " + hash + "\n        claims:\n          scope: admin", table: access, wantErr: `claim "scope" is not allowed`},
+	})
+}
+
+func TestValidate_TLS(t *testing.T) {
+	const certs = "tls:\n    certFile: tls.crt\n    keyFile: tls.key"
+	clientAuth := func(want string) func(t *testing.T, cfg *Config) {
+		return func(t *testing.T, cfg *Config) {
+			if cfg.Server.TLS.ClientAuth != want {
+				t.Fatalf("expected clientAuth %q, got %q", want, cfg.Server.TLS.ClientAuth)
+			}
+		}
+	}
+	testValidate(t, []validateCase{
+		{name: "server only", server: certs, check: clientAuth("none")},
+		{name: "client CA defaults to require", server: certs + "\n    clientCAFile: ca.crt\n    clientCertIdentity: true", check: clientAuth("require")},
+		{name: "optional", server: certs + "\n    clientCAFile: ca.crt\n    clientAuth: optional", check: clientAuth("optional")},
+		{name: "missing key", server: "tls:\n    certFile: tls.crt", wantErr: "keyFile is required"},
+		{name: "missing cert", server: "tls:\n    keyFile: tls.key", wantErr: "certFile is required"},
+		{name: "require without CA", server: certs + "\n    clientAuth: require", wantErr: "clientAuth require requires clientCAFile"},
+		{name: "identity without CA", server: certs + "\n    clientCertIdentity: true", wantErr: "clientCertIdentity requires clientCAFile"},
+		{name: "bad mode", server: certs + "\n    clientAuth: maybe", wantErr: "clientAuth must be one of"},
+	})
+}
+
+func TestValidate_AuditAndAdmin(t *testing.T) {
+	sink := func(want string) func(t *testing.T, cfg *Config) {
+		return func(t *testing.T, cfg *Config) {
+			if cfg.Tables[0].Audit.Sink != want {
+				t.Fatalf("expected sink %q, got %q", want, cfg.Tables[0].Audit.Sink)
+			}
+		}
+	}
+	testValidate(t, []validateCase{
+		{name: "default sink", server: jwtServer, table: "audit:\n      diff: true", check: sink("table")},
+		{name: "log sink", table: "audit:\n      sink: log", check: sink("log")},
+		{name: "bad sink", table: "audit:\n      sink: kafka", wantErr: `table "items": audit.sink must be table or log`},
+		{name: "admin", server: jwtServer + "\n  admin:\n    access:\n      scopes: [admin]"},
+		{name: "admin without auth", server: "admin:\n    access:\n      scopes: [admin]", wantErr: "server.admin.access requires server.jwt.enabled"},
+		{name: "empty admin rule", server: jwtServer + "\n  admin:\n    access: {}", wantErr: "server.admin.access must list scopes, roles, or claims"},
+	})
+}
+
+func TestValidate_RateLimit(t *testing.T) {
+	defaultPageSize := func(t *testing.T, cfg *Config) {
+		if cfg.Server.MaxPageSize != DefaultMaxPageSize {
+			t.Fatalf("expected default max page size, got %d", cfg.Server.MaxPageSize)
+		}
+	}
+	testValidate(t, []validateCase{
+		{name: "defaults", check: defaultPageSize},
+		{name: "burst defaults to rate", server: "rateLimit:\n    perClient:\n      requestsPerSecond: 2.5", check: func(t *testing.T, cfg *Config) {
+			if cfg.Server.RateLimit.PerClient.Burst != 3 {
+				t.Fatalf("expected burst 3, got %d", cfg.Server.RateLimit.PerClient.Burst)
+			}
+		}},
+		{name: "client override", server: "rateLimit:\n    clients:\n      apikey:batch:\n        requestsPerSecond: 100\n        burst: 200"},
+		{name: "table limit", table: "rateLimit:\n      requestsPerSecond: 5"},
+		{name: "zero rate", server: "rateLimit:\n    global:\n      burst: 5", wantErr: "server.rateLimit.global.requestsPerSecond must be positive"},
+		{name: "negative burst", table: "rateLimit:\n      requestsPerSecond: 5\n      burst: -1", wantErr: `table "items": rateLimit.burst must not be negative`},
+		{name: "negative scans", server: "rateLimit:\n    maxConcurrentScans: -1", wantErr: "maxConcurrentScans must not be negative"},
+		{name: "negative page size", server: "maxPageSize: -1", wantErr: "server.maxPageSize must not be negative"},
+	})
+}
+
+func TestValidate_AdminPort(t *testing.T) {
+	testValidate(t, []validateCase{
+		{name: "separate port", server: "admin:\n    port: 9090"},
+		{name: "same as default port", server: "admin:\n    port: 8080", wantErr: "server.admin.port must differ from server.port"},
+		{name: "out of range", server: "admin:\n    port: 70000", wantErr: "server.admin.port must be between 1 and 65535"},
+		{name: "explain without access", server: "admin:\n    explain: true", wantErr: "server.admin.explain requires server.admin.access"},
+	})
+}
+
+func TestValidate_Tracing(t *testing.T) {
+	const tracing = "tracing:\n    "
+	testValidate(t, []validateCase{
+		{name: "otlp defaults", server: tracing + "exporter: otlp", check: func(t *testing.T, cfg *Config) {
+			tr := cfg.Server.Tracing
+			if tr.Endpoint != DefaultTracingEndpoint || tr.ServiceName != "itemservicecentral" || tr.SampleRatio == nil || *tr.SampleRatio != 1 {
+				t.Fatalf("defaults not applied: %+v", tr)
+			}
+		}},
+		{name: "file", server: tracing + "exporter: file\n    file: /tmp/spans.jsonl"},
+		{name: "settings without exporter", server: tracing + "sampleRatio: 0.5", wantErr: "server.tracing: exporter is required"},
+		{name: "unknown exporter", server: tracing + "exporter: jaeger", wantErr: "server.tracing.exporter must be one of"},
+		{name: "file without path", server: tracing + "exporter: file", wantErr: "exporter file requires file"},
+		{name: "endpoint for stdout", server: tracing + "exporter: stdout\n    endpoint: http://collector:4318", wantErr: "endpoint and headers require exporter otlp"},
+		{name: "endpoint not a URL", server: tracing + "exporter: otlp\n    endpoint: collector:4318", wantErr: "must be an http or https URL"},
+		{name: "ratio out of range", server: tracing + "exporter: stdout\n    sampleRatio: 2", wantErr: "sampleRatio must be between 0 and 1"},
+	})
+}
+
+func TestValidate_Logging(t *testing.T) {
+	testValidate(t, []validateCase{
+		{name: "defaults", check: func(t *testing.T, cfg *Config) {
+			if cfg.Logging.Level != "info" || cfg.Logging.Format != "text" {
+				t.Fatalf("defaults not applied: %+v", cfg.Logging)
+			}
+		}},
+		{name: "json at debug", logging: "level: debug\n  format: json"},
+		{name: "unknown level", logging: "level: trace", wantErr: "logging.level must be one of"},
+		{name: "unknown format", logging: "format: xml", wantErr: "logging.format must be text or json"},
+		{name: "slow query threshold", logging: "slowQueryThreshold: 250ms", check: func(t *testing.T, cfg *Config) {
+			if cfg.Logging.SlowQueryThreshold != 250*time.Millisecond {
+				t.Fatalf("expected a 250ms threshold, got %v", cfg.Logging.SlowQueryThreshold)
+			}
+		}},
+		{name: "negative slow query threshold", logging: "slowQueryThreshold: -1s", wantErr: "logging.slowQueryThreshold must not be negative"},
+	})
+}
@@ -14,10 +14,11 @@ type MinimalTableStructure struct {
 }
 
 type MinimalTable struct {
-	Name       string         `yaml:"name"`
-	PrimaryKey MinimalKey     `yaml:"primaryKey"`
-	RangeKey   *MinimalKey    `yaml:"rangeKey,omitempty"`
-	Indexes    []MinimalIndex `yaml:"indexes,omitempty"`
+	Name         string         `yaml:"name"`
+	PrimaryKey   MinimalKey     `yaml:"primaryKey"`
+	RangeKey     *MinimalKey    `yaml:"rangeKey,omitempty"`
+	TenantColumn bool           `yaml:"tenantColumn,omitempty"`
+	Indexes      []MinimalIndex `yaml:"indexes,omitempty"`
 }
 
 type MinimalIndex struct {
@@ -43,7 +44,8 @@ func BuildMinimalTableStructure(tables []TableConfig) MinimalTableStructure {
 			PrimaryKey: MinimalKey{
 				Field: t.PrimaryKey.Field,
 			},
-			Indexes: make([]MinimalIndex, 0, len(t.Indexes)),
+			TenantColumn: t.TenantClaim != "",
+			Indexes:      make([]MinimalIndex, 0, len(t.Indexes)),
 		}
 
 		if t.RangeKey != nil {
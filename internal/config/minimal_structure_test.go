@@ -106,3 +106,18 @@ func TestBuildMinimalTableStructure_CanonicalOrdering(t *testing.T) {
 		t.Fatalf("indexes not sorted by name: %#v", got.Tables[1].Indexes)
 	}
 }
+
+func TestBuildMinimalTableStructure_TenantClaim(t *testing.T) {
+	tables := []TableConfig{
+		{Name: "items", PrimaryKey: KeyConfig{Field: "itemId"}, TenantClaim: "org"},
+		{Name: "users", PrimaryKey: KeyConfig{Field: "userId"}},
+	}
+
+	got := BuildMinimalTableStructure(tables)
+	if !got.Tables[0].TenantColumn {
+		t.Fatal("expected tenant column for table with tenantClaim")
+	}
+	if got.Tables[1].TenantColumn {
+		t.Fatal("expected no tenant column for table without tenantClaim")
+	}
+}
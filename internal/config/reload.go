@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// CheckReload reports whether next can replace current in a running server.
+// Changes to the minimal table structure are refused, since the database no
+// longer matches them until migrate runs. Tables, logging, swagger, the
+// admin access rule, page size, and scan cap, and the JWT issuer, audience,
+// and roles claim are applied on reload; CheckReload returns the other
+// settings that changed, which only take effect after a restart.
+func CheckReload(current, next *Config) ([]string, error) {
+	if !reflect.DeepEqual(BuildMinimalTableStructure(current.Tables), BuildMinimalTableStructure(next.Tables)) {
+		return nil, fmt.Errorf("table structure changed; run migrate and restart to apply it")
+	}
+
+	var restart []string
+	compare := func(path string, a, b any) {
+		if !reflect.DeepEqual(a, b) {
+			restart = append(restart, path)
+		}
+	}
+
+	compare("database", current.Database, next.Database)
+	compare("server.port", current.Server.Port, next.Server.Port)
+	compare("server.apiKeys", current.Server.APIKeys, next.Server.APIKeys)
+	compare("server.tls", current.Server.TLS, next.Server.TLS)
+	compare("server.tracing", current.Server.Tracing, next.Server.Tracing)
+	compare("server.admin.port", current.Server.Admin.Port, next.Server.Admin.Port)
+
+	cj, nj := current.Server.JWT, next.Server.JWT
+	cj.Audience, nj.Audience = "", ""
+	cj.RolesClaim, nj.RolesClaim = "", ""
+	// Without a JWKS URL the issuer's discovery document locates the keys,
+	// which are only fetched from there at startup.
+	if cj.JWKSUrl != "" || !cj.Enabled {
+		cj.Issuer, nj.Issuer = "", ""
+	}
+	compare("server.jwt", cj, nj)
+
+	cr, nr := current.Server.RateLimit, next.Server.RateLimit
+	cr.MaxConcurrentScans, nr.MaxConcurrentScans = 0, 0
+	compare("server.rateLimit", cr, nr)
+
+	return restart, nil
+}
+
+// Served returns the config a running server serves after reloading next:
+// next with the settings CheckReload reports as restart-only kept from
+// current, so the following reload is checked against what is served.
+func Served(current, next *Config) *Config {
+	served := *next
+	served.Database = current.Database
+	served.Server.Port = current.Server.Port
+	served.Server.APIKeys = current.Server.APIKeys
+	served.Server.TLS = current.Server.TLS
+	served.Server.Tracing = current.Server.Tracing
+	served.Server.Admin.Port = current.Server.Admin.Port
+
+	jwt := current.Server.JWT
+	jwt.Audience = next.Server.JWT.Audience
+	jwt.RolesClaim = next.Server.JWT.RolesClaim
+	if jwt.JWKSUrl != "" || !jwt.Enabled {
+		jwt.Issuer = next.Server.JWT.Issuer
+	}
+	served.Server.JWT = jwt
+
+	rateLimit := current.Server.RateLimit
+	rateLimit.MaxConcurrentScans = next.Server.RateLimit.MaxConcurrentScans
+	served.Server.RateLimit = rateLimit
+	return &served
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func reloadTestConfig() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port: 8080,
+			JWT: JWTConfig{
+				Enabled:  true,
+				JWKSUrl:  "https://idp.example.com/jwks",
+				Issuer:   "https://idp.example.com",
+				Audience: "items",
+			},
+		},
+		Tables: []TableConfig{
+			{
+				Name:       "items",
+				PrimaryKey: KeyConfig{Field: "itemId", Pattern: "^[a-z]+$"},
+				Schema:     map[string]any{"type": "object"},
+				Indexes: []IndexConfig{
+					{Name: "by_status", PrimaryKey: KeyConfig{Field: "status"}},
+				},
+			},
+		},
+	}
+}
+
+func TestCheckReload(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     func(c *Config) // adjusts the running config before next is derived from it
+		change      func(c *Config)
+		wantRestart []string
+		wantErr     string
+	}{
+		{
+			name: "non-structural table settings",
+			change: func(c *Config) {
+				c.Tables[0].PrimaryKey.Pattern = "^[a-z0-9]+$"
+				c.Tables[0].AllowTableScan = true
+				c.Tables[0].Schema = map[string]any{"type": "object", "required": []any{"itemId"}}
+				c.Tables[0].Indexes[0].AllowIndexScan = true
+				c.Tables[0].Indexes[0].Projection = &IndexProjection{Type: "KEYS_ONLY"}
+			},
+		},
+		{
+			name: "issuer, audience, swagger, and logging",
+			change: func(c *Config) {
+				c.Server.JWT.Issuer = "https://idp2.example.com"
+				c.Server.JWT.Audience = "orders"
+				c.Server.Swagger.Enabled = true
+				c.Logging.Level = "debug"
+			},
+		},
+		{
+			name: "new index",
+			change: func(c *Config) {
+				c.Tables[0].Indexes = append(c.Tables[0].Indexes, IndexConfig{Name: "by_owner", PrimaryKey: KeyConfig{Field: "owner"}})
+			},
+			wantErr: "table structure changed",
+		},
+		{
+			name:    "key field",
+			change:  func(c *Config) { c.Tables[0].PrimaryKey.Field = "id" },
+			wantErr: "table structure changed",
+		},
+		{
+			name: "restart-only settings",
+			change: func(c *Config) {
+				c.Server.Port = 9000
+				c.Server.JWT.JWKSUrl = "https://idp2.example.com/jwks"
+				c.Database.Schema = "inventory"
+			},
+			wantRestart: []string{"database", "server.port", "server.jwt"},
+		},
+		{
+			name:    "issuer used for discovery",
+			current: func(c *Config) { c.Server.JWT.JWKSUrl = "" },
+			change: func(c *Config) {
+				c.Server.JWT.Issuer = "https://idp2.example.com"
+			},
+			wantRestart: []string{"server.jwt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, next := reloadTestConfig(), reloadTestConfig()
+			if tt.current != nil {
+				tt.current(current)
+				tt.current(next)
+			}
+			tt.change(next)
+
+			restart, err := CheckReload(current, next)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(restart, tt.wantRestart) {
+				t.Fatalf("expected restart-only changes %v, got %v", tt.wantRestart, restart)
+			}
+		})
+	}
+}
+
+func TestServed(t *testing.T) {
+	current, next := reloadTestConfig(), reloadTestConfig()
+	next.Server.Port = 9000
+	next.Server.JWT.JWKSUrl = "https://idp2.example.com/jwks"
+	next.Server.JWT.Audience = "orders"
+	next.Server.RateLimit.MaxConcurrentScans = 4
+	next.Server.RateLimit.Global = &RateLimitConfig{RequestsPerSecond: 10}
+	next.Database.Schema = "inventory"
+	next.Logging.Level = "debug"
+
+	served := Served(current, next)
+	if restart, err := CheckReload(current, served); err != nil || len(restart) != 0 {
+		t.Fatalf("expected the served config to keep every restart-only setting, got %v, %v", restart, err)
+	}
+	if served.Server.JWT.Audience != "orders" || served.Server.RateLimit.MaxConcurrentScans != 4 || served.Logging.Level != "debug" {
+		t.Fatalf("expected the reloadable settings of next, got %+v", served)
+	}
+
+	// Reverting the file to the served settings needs no restart.
+	if restart, err := CheckReload(served, reloadTestConfig()); err != nil || len(restart) != 0 {
+		t.Fatalf("expected no restart-only changes, got %v, %v", restart, err)
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKey is an API key stored in the _api_keys table. The key itself is never
+// stored, only its hash.
+type APIKey struct {
+	Name      string
+	Scopes    []string
+	Claims    map[string]string
+	ExpiresAt *time.Time
+}
+
+// apiKeysTableStatement creates the _api_keys table, keyed by the hex
+// SHA-256 of each key. %s is the qualified table name.
+const apiKeysTableStatement = `CREATE TABLE IF NOT EXISTS %s (
+	key_hash TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	scopes JSONB NOT NULL DEFAULT '[]',
+	claims JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ
+)`
+
+// EnsureAPIKeysTable creates the _api_keys table in schema if it does not
+// exist. An empty schema uses the connection search path.
+func EnsureAPIKeysTable(db *sql.DB, schema string) error {
+	if schema != "" {
+		if _, err := db.Exec(`CREATE SCHEMA IF NOT EXISTS ` + pq.QuoteIdentifier(schema)); err != nil {
+			return fmt.Errorf("creating schema %q: %w", schema, err)
+		}
+	}
+	if _, err := db.Exec(fmt.Sprintf(apiKeysTableStatement, qualifiedName(schema, "_api_keys"))); err != nil {
+		return fmt.Errorf("failed to create _api_keys table: %w", err)
+	}
+	return nil
+}
+
+// InsertAPIKey stores a new API key by its hash.
+func InsertAPIKey(db *sql.DB, schema, hash string, key APIKey) error {
+	scopes, err := json.Marshal(nonNilStrings(key.Scopes))
+	if err != nil {
+		return fmt.Errorf("marshal scopes: %w", err)
+	}
+	claims := key.Claims
+	if claims == nil {
+		claims = map[string]string{}
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return fmt.Errorf("marshal claims: %w", err)
+	}
+
+	if _, err := db.Exec(
+		fmt.Sprintf(`INSERT INTO %s (key_hash, name, scopes, claims, expires_at) VALUES ($1, $2, $3, $4, $5)`, qualifiedName(schema, "_api_keys")),
+		hash, key.Name, scopes, claimsJSON, key.ExpiresAt,
+	); err != nil {
+		return fmt.Errorf("failed to insert API key: %w", err)
+	}
+	return nil
+}
+
+// LookupAPIKey returns the active API key with the given hash from the
+// Store's default schema, or nil when there is none: the key is unknown,
+// revoked, expired, or the _api_keys table does not exist.
+func (s *Store) LookupAPIKey(ctx context.Context, hash string) (*APIKey, error) {
+	var (
+		key        APIKey
+		scopesJSON []byte
+		claimsJSON []byte
+	)
+	query := fmt.Sprintf(
+		`SELECT name, scopes, claims, expires_at FROM %s
+		 WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > now())`,
+		qualifiedName(s.schema, "_api_keys"),
+	)
+	err := s.queryRow(ctx, "LookupAPIKey", "_api_keys", "", query, []any{hash},
+		&key.Name, &scopesJSON, &claimsJSON, &key.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) || IsUndefinedTableError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+
+	if err := json.Unmarshal(scopesJSON, &key.Scopes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal API key scopes: %w", err)
+	}
+	if err := json.Unmarshal(claimsJSON, &key.Claims); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal API key claims: %w", err)
+	}
+	return &key, nil
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,262 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// auditTableStatement creates the append-only _audit table of item writes.
+// The service only ever inserts into it.
+const auditTableStatement = `CREATE TABLE IF NOT EXISTS _audit (
+	id BIGSERIAL PRIMARY KEY,
+	occurred_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	request_id TEXT NOT NULL DEFAULT '',
+	subject TEXT NOT NULL DEFAULT '',
+	operation TEXT NOT NULL,
+	table_name TEXT NOT NULL,
+	pk TEXT NOT NULL,
+	rk TEXT,
+	tenant TEXT,
+	before JSONB,
+	after JSONB
+)`
+
+// auditIndexStatements index _audit for lookups by item and by subject.
+var auditIndexStatements = []string{
+	`CREATE INDEX IF NOT EXISTS _audit_item_idx ON _audit (table_name, pk, rk, id)`,
+	`CREATE INDEX IF NOT EXISTS _audit_subject_idx ON _audit (subject, id)`,
+}
+
+// AuditEntry is one recorded write. Before and After hold the item data
+// around the write when the table records diffs.
+type AuditEntry struct {
+	ID        int64          `json:"id"`
+	Time      time.Time      `json:"time"`
+	RequestID string         `json:"requestId,omitempty"`
+	Subject   string         `json:"subject,omitempty"`
+	Operation string         `json:"operation"`
+	Table     string         `json:"table"`
+	PK        string         `json:"pk"`
+	RK        *string        `json:"rk,omitempty"`
+	Tenant    string         `json:"tenant,omitempty"`
+	Before    map[string]any `json:"before,omitempty"`
+	After     map[string]any `json:"after,omitempty"`
+}
+
+// AuditQuery filters the audit log. Empty fields match everything; RK is
+// only applied together with PK.
+type AuditQuery struct {
+	Table     string
+	PK        string
+	RK        *string
+	Subject   string
+	Limit     int
+	PageToken string // encoded id of the last entry of the previous page
+}
+
+// AuditResult holds a page of audit entries, newest first.
+type AuditResult struct {
+	Entries       []AuditEntry
+	NextPageToken string // empty if no more pages
+}
+
+type auditContextKey struct{}
+
+// WithAudit returns a context whose item writes also insert e into the
+// request schema's _audit table, in the same transaction, so an item never
+// changes without its audit entry.
+func WithAudit(ctx context.Context, e AuditEntry) context.Context {
+	return context.WithValue(ctx, auditContextKey{}, e)
+}
+
+// write runs an item write and returns the number of rows it affected. With
+// an audit entry on the context, the entry is inserted in the write's
+// transaction when the write affected a row, and a failed insert rolls the
+// write back.
+func (s *Store) write(ctx context.Context, op, table, query string, args ...any) (_ int64, err error) {
+	entry, ok := ctx.Value(auditContextKey{}).(AuditEntry)
+	if !ok {
+		return s.exec(ctx, op, table, query, args...)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	n, err := s.execOn(ctx, tx, op, table, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		if err = s.insertAudit(ctx, tx, entry); err != nil {
+			return 0, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return n, nil
+}
+
+// insertAudit records a write in the request schema's _audit table, with
+// the request's tenant when one is set.
+func (s *Store) insertAudit(ctx context.Context, tx *sql.Tx, e AuditEntry) error {
+	before, err := marshalAuditData(e.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshalAuditData(e.After)
+	if err != nil {
+		return err
+	}
+	var tenant *string
+	if t, ok := tenantFromContext(ctx); ok {
+		tenant = &t
+	}
+
+	query := fmt.Sprintf(
+		`INSERT INTO %s (request_id, subject, operation, table_name, pk, rk, tenant, before, after)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		s.table(ctx, "_audit"),
+	)
+	_, err = s.execOn(ctx, tx, "AppendAudit", "_audit", query,
+		e.RequestID, e.Subject, e.Operation, e.Table, e.PK, e.RK, tenant, before, after,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// QueryAudit returns a page of the request schema's audit log matching q,
+// newest first.
+func (s *Store) QueryAudit(ctx context.Context, q AuditQuery) (_ *AuditResult, err error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if q.Table != "" {
+		add("table_name = $%d", q.Table)
+	}
+	if q.PK != "" {
+		add("pk = $%d", q.PK)
+		if q.RK != nil {
+			add("rk = $%d", *q.RK)
+		}
+	}
+	if q.Subject != "" {
+		add("subject = $%d", q.Subject)
+	}
+	// An invalid page token is ignored, as for item listings.
+	if id, ok := decodeAuditCursor(q.PageToken); ok {
+		add("id < $%d", id)
+	}
+
+	query := fmt.Sprintf(
+		`SELECT id, occurred_at, request_id, subject, operation, table_name, pk, rk, tenant, before, after FROM %s`,
+		s.table(ctx, "_audit"),
+	)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, limit+1)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	defer s.logIfSlow(ctx, "QueryAudit", "_audit", "", query, args, time.Now())
+	ctx, span := startSpan(ctx, "QueryAudit", "_audit", query)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	result := &AuditResult{Entries: []AuditEntry{}}
+	for rows.Next() {
+		var (
+			e             AuditEntry
+			rk, tenant    sql.NullString
+			before, after []byte
+		)
+		if err := rows.Scan(&e.ID, &e.Time, &e.RequestID, &e.Subject, &e.Operation, &e.Table, &e.PK, &rk, &tenant, &before, &after); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if rk.Valid {
+			e.RK = &rk.String
+		}
+		e.Tenant = tenant.String
+		if before != nil {
+			if err := json.Unmarshal(before, &e.Before); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal audit before: %w", err)
+			}
+		}
+		if after != nil {
+			if err := json.Unmarshal(after, &e.After); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal audit after: %w", err)
+			}
+		}
+		result.Entries = append(result.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+	span.SetAttributes(attribute.Int("db.rows", len(result.Entries)))
+
+	if len(result.Entries) > limit {
+		result.Entries = result.Entries[:limit]
+		result.NextPageToken = encodeAuditCursor(result.Entries[limit-1].ID)
+	}
+	return result, nil
+}
+
+// marshalAuditData encodes an item image for a JSONB column, or NULL when
+// there is none.
+func marshalAuditData(data map[string]any) (any, error) {
+	if data == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal audit data: %w", err)
+	}
+	return b, nil
+}
+
+func encodeAuditCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeAuditCursor(s string) (int64, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	return id, err == nil
+}
@@ -1,13 +1,13 @@
 package database
 
 import (
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
@@ -32,55 +32,67 @@ func TablesConfigHash(tables []config.TableConfig) (string, error) {
 	return hex.EncodeToString(sum[:]), nil
 }
 
-// ValidateTablesConfigHash checks whether the DB-stored config hash matches the current config.
-func ValidateTablesConfigHash(db *sql.DB, tables []config.TableConfig) error {
+// ValidateTablesConfigHash checks whether the DB-stored config hash in the
+// given schema matches the current config. An empty schema uses the search path.
+func ValidateTablesConfigHash(ctx context.Context, db *sql.DB, schema string, tables []config.TableConfig) error {
 	expectedHash, err := TablesConfigHash(tables)
 	if err != nil {
 		return fmt.Errorf("compute tables config hash: %w", err)
 	}
 
-	var configJSON []byte
-	err = db.QueryRow(`SELECT config FROM _meta WHERE table_name = $1`, metaConfigHashRowName).Scan(&configJSON)
+	storedHash, err := storedTablesConfigHash(ctx, db, schema)
 	switch {
 	case err == sql.ErrNoRows:
 		return fmt.Errorf("database config hash is missing in _meta; run migrate or use --skip-config-validation")
 	case err != nil:
-		if isUndefinedTableError(err) {
+		if IsUndefinedTableError(err) {
 			return fmt.Errorf("_meta table does not exist; run migrate or use --skip-config-validation")
 		}
-		return fmt.Errorf("querying _meta config hash: %w", err)
+		return err
 	}
 
-	var stored storedConfigHash
-	if err := json.Unmarshal(configJSON, &stored); err != nil {
-		return fmt.Errorf("parse stored _meta config hash: %w", err)
-	}
-	if strings.TrimSpace(stored.ConfigHash) == "" {
+	if strings.TrimSpace(storedHash) == "" {
 		return fmt.Errorf("stored _meta config hash is empty; run migrate or use --skip-config-validation")
 	}
-	if stored.ConfigHash != expectedHash {
-		return fmt.Errorf("configuration hash mismatch: database=%s config=%s", stored.ConfigHash, expectedHash)
+	if storedHash != expectedHash {
+		return fmt.Errorf("configuration hash mismatch: database=%s config=%s", storedHash, expectedHash)
 	}
 
 	return nil
 }
 
-func upsertTablesConfigHash(tx *sql.Tx, tables []config.TableConfig, dryRun bool) error {
-	hash, err := TablesConfigHash(tables)
-	if err != nil {
-		return fmt.Errorf("compute tables config hash: %w", err)
+// storedTablesConfigHash reads the config hash stored in the schema's _meta
+// table. It returns sql.ErrNoRows when none is stored and the driver's error
+// unwrapped when the query fails, so callers can test for undefined_table.
+func storedTablesConfigHash(ctx context.Context, db *sql.DB, schema string) (string, error) {
+	var configJSON []byte
+	err := db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT config FROM %s WHERE table_name = $1`, qualifiedName(schema, "_meta")),
+		metaConfigHashRowName,
+	).Scan(&configJSON)
+	switch {
+	case err == sql.ErrNoRows:
+		return "", err
+	case err != nil:
+		if IsUndefinedTableError(err) {
+			return "", err
+		}
+		return "", fmt.Errorf("querying _meta config hash: %w", err)
 	}
 
+	var stored storedConfigHash
+	if err := json.Unmarshal(configJSON, &stored); err != nil {
+		return "", fmt.Errorf("parse stored _meta config hash: %w", err)
+	}
+	return stored.ConfigHash, nil
+}
+
+func upsertTablesConfigHash(tx execer, hash string) error {
 	configJSON, err := json.Marshal(storedConfigHash{ConfigHash: hash})
 	if err != nil {
 		return fmt.Errorf("marshal _meta config hash: %w", err)
 	}
 
-	if dryRun {
-		log.Printf("[dry-run] would upsert _meta config hash %q", hash)
-		return nil
-	}
-
 	if _, err := tx.Exec(
 		`INSERT INTO _meta (table_name, config)
 		 VALUES ($1, $2)
@@ -95,7 +107,8 @@ func upsertTablesConfigHash(tx *sql.Tx, tables []config.TableConfig, dryRun bool
 	return nil
 }
 
-func isUndefinedTableError(err error) bool {
+// IsUndefinedTableError reports whether err is a PostgreSQL undefined_table (42P01) error.
+func IsUndefinedTableError(err error) bool {
 	var pqErr *pq.Error
 	return errors.As(err, &pqErr) && pqErr.Code == "42P01"
 }
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/tracing"
+)
+
+// SetSlowQueryThreshold logs Store queries that take at least d, including
+// reading their rows. Zero disables the log. It may be called while queries
+// run.
+func (s *Store) SetSlowQueryThreshold(d time.Duration) {
+	s.slowQuery.Store(int64(d))
+}
+
+// logIfSlow logs a query that started at start when it took at least the
+// slow query threshold. Call it deferred, with time.Now() evaluated at the
+// defer statement.
+func (s *Store) logIfSlow(ctx context.Context, op, table, index, query string, args []any, start time.Time) {
+	threshold := time.Duration(s.slowQuery.Load())
+	elapsed := time.Since(start)
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+	slog.WarnContext(ctx, "slow query",
+		"operation", op,
+		"table", table,
+		"index", index,
+		"statement", query,
+		"params", redactParams(args),
+		"duration_ms", float64(elapsed.Microseconds())/1000,
+	)
+}
+
+// redactParams describes query parameters without their values, which hold
+// keys and item data: strings and byte slices by length, other values by
+// type. Integers are kept, since the Store binds only limits and cursors as
+// integers.
+func redactParams(args []any) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			out[i] = "null"
+		case string:
+			out[i] = fmt.Sprintf("string(%d)", len(v))
+		case *string:
+			if v == nil {
+				out[i] = "null"
+			} else {
+				out[i] = fmt.Sprintf("string(%d)", len(*v))
+			}
+		case []byte:
+			out[i] = fmt.Sprintf("bytes(%d)", len(v))
+		case int, int64:
+			out[i] = fmt.Sprint(v)
+		default:
+			out[i] = fmt.Sprintf("%T", v)
+		}
+	}
+	return out
+}
+
+// QueryPlan is the output of EXPLAIN (ANALYZE, BUFFERS) for a list query.
+type QueryPlan struct {
+	Operation string   // Store operation, such as QueryIndex
+	Statement string   // the SQL explained, with values as $n parameters
+	Lines     []string // the plan in PostgreSQL's text format
+}
+
+// explain runs query under EXPLAIN (ANALYZE, BUFFERS), which executes it,
+// and returns the plan.
+func (s *Store) explain(ctx context.Context, op, table, index, query string, args []any) (_ *QueryPlan, err error) {
+	statement := "EXPLAIN (ANALYZE, BUFFERS) " + query
+	ctx, span := startSpan(ctx, "Explain"+op, table, statement, indexAttributes(index)...)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	plan := &QueryPlan{Operation: op, Statement: query, Lines: []string{}}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("failed to scan query plan: %w", err)
+		}
+		plan.Lines = append(plan.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query plan iteration error: %w", err)
+	}
+	return plan, nil
+}
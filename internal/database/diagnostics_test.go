@@ -0,0 +1,52 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactParams(t *testing.T) {
+	rk := "line-1"
+	got := redactParams([]any{"secret-pk", &rk, (*string)(nil), []byte(`{"a":1}`), 51, int64(7), time.Now(), nil})
+	want := []string{"string(9)", "string(6)", "null", "bytes(7)", "51", "7", "time.Time", "null"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestLogIfSlow(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	s := NewStore(nil)
+	query := `SELECT pk, rk, data FROM "orders" WHERE data->>'status' = $1 ORDER BY pk LIMIT $2`
+	args := []any{"shipped", 51}
+
+	s.logIfSlow(context.Background(), "QueryIndex", "orders", "byStatus", query, args, time.Now().Add(-time.Second))
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing logged without a threshold, got %q", buf.String())
+	}
+
+	s.SetSlowQueryThreshold(500 * time.Millisecond)
+	s.logIfSlow(context.Background(), "QueryIndex", "orders", "byStatus", query, args, time.Now())
+	if buf.Len() != 0 {
+		t.Fatalf("expected a fast query not to be logged, got %q", buf.String())
+	}
+
+	s.logIfSlow(context.Background(), "QueryIndex", "orders", "byStatus", query, args, time.Now().Add(-time.Second))
+	out := buf.String()
+	for _, want := range []string{`msg="slow query"`, "operation=QueryIndex", "table=orders", "index=byStatus", "data->>'status' = $1", "params=\"[string(7) 51]\""} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected log to contain %s, got %q", want, out)
+		}
+	}
+	if strings.Contains(out, "shipped") {
+		t.Errorf("expected parameter values to be redacted, got %q", out)
+	}
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// migrationsTableStatement creates the append-only ledger of migrate runs.
+const migrationsTableStatement = `CREATE TABLE IF NOT EXISTS _migrations (
+	id BIGSERIAL PRIMARY KEY,
+	executed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	tool_version TEXT NOT NULL,
+	config_hash TEXT NOT NULL,
+	statements JSONB NOT NULL
+)`
+
+// recordMigration appends a migrate run to the _migrations ledger.
+func recordMigration(tx execer, configHash, toolVersion string, statements []string) error {
+	if statements == nil {
+		statements = []string{}
+	}
+	payload, err := json.Marshal(statements)
+	if err != nil {
+		return fmt.Errorf("marshal executed statements: %w", err)
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO _migrations (tool_version, config_hash, statements) VALUES ($1, $2, $3)`,
+		toolVersion, configHash, payload,
+	); err != nil {
+		return fmt.Errorf("insert _migrations entry: %w", err)
+	}
+	return nil
+}
+
+// MigrationStatus is the migration state of a schema.
+type MigrationStatus struct {
+	// ConfigHash is the table-structure hash stored in _meta; empty before
+	// the first migrate.
+	ConfigHash string `json:"configHash"`
+	// LastMigration is the latest _migrations entry; nil when none is
+	// recorded.
+	LastMigration *MigrationRecord `json:"lastMigration,omitempty"`
+}
+
+// MigrationRecord is one applied migrate run.
+type MigrationRecord struct {
+	ExecutedAt  time.Time `json:"executedAt"`
+	ToolVersion string    `json:"toolVersion"`
+	ConfigHash  string    `json:"configHash"`
+	Statements  int       `json:"statements"`
+}
+
+// ReadMigrationStatus returns the migration state of the schema. A schema
+// that has never been migrated has an empty status. An empty schema uses the
+// search path.
+func ReadMigrationStatus(ctx context.Context, db *sql.DB, schema string) (*MigrationStatus, error) {
+	status := &MigrationStatus{}
+
+	hash, err := storedTablesConfigHash(ctx, db, schema)
+	switch {
+	case err == sql.ErrNoRows || IsUndefinedTableError(err):
+		return status, nil
+	case err != nil:
+		return nil, err
+	}
+	status.ConfigHash = hash
+
+	var rec MigrationRecord
+	err = db.QueryRowContext(ctx, fmt.Sprintf(
+		`SELECT executed_at, tool_version, config_hash, jsonb_array_length(statements)
+		 FROM %s ORDER BY id DESC LIMIT 1`, qualifiedName(schema, "_migrations")),
+	).Scan(&rec.ExecutedAt, &rec.ToolVersion, &rec.ConfigHash, &rec.Statements)
+	switch {
+	case err == sql.ErrNoRows || IsUndefinedTableError(err):
+		return status, nil
+	case err != nil:
+		return nil, fmt.Errorf("querying _migrations: %w", err)
+	}
+	status.LastMigration = &rec
+	return status, nil
+}
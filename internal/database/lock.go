@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// migrationLockKey identifies the session-level advisory lock held while a
+// migration runs. Every migrate process uses the same key, so concurrent runs
+// against one database are serialized.
+const migrationLockKey int64 = 0x69736331_6d696772 // "isc1migr"
+
+// lockPollInterval is how often a waiting migration retries the advisory lock.
+const lockPollInterval = time.Second
+
+// acquireMigrationLock takes the migration advisory lock on a dedicated
+// connection, waiting up to timeout for another migration to release it. A
+// zero timeout waits indefinitely. The returned function releases the lock and
+// returns the connection to the pool.
+func acquireMigrationLock(db *sql.DB, timeout time.Duration) (func(), error) {
+	ctx := context.Background()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("open lock connection: %w", err)
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	waiting := false
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, migrationLockKey).Scan(&acquired); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if acquired {
+			break
+		}
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			conn.Close()
+			return nil, fmt.Errorf("timed out after %s waiting for another migration to release the migration lock", timeout)
+		}
+		if !waiting {
+			slog.Info("waiting for another migration to release the migration lock")
+			waiting = true
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	return func() {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey); err != nil {
+			slog.Error("failed to release migration lock", "error", err)
+		}
+		conn.Close()
+	}, nil
+}
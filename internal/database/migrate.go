@@ -1,70 +1,201 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/transform"
+	"github.com/lib/pq"
 )
 
 // MigrateOptions controls the behavior of the Migrate function.
 type MigrateOptions struct {
-	Cleanup bool // if true, delete tables and indexes not in config
-	DryRun  bool // if true, only print what would change
+	Cleanup               bool                  // if true, delete tables and indexes not in config
+	DryRun                bool                  // if true, only print what would change
+	ConcurrentIndexes     bool                  // if true, build indexes with CREATE INDEX CONCURRENTLY after the transaction commits
+	RebuildDriftedIndexes bool                  // if true, drop and recreate indexes whose definition no longer matches config
+	Transforms            []transform.Migration // data transformations applied in version order, each exactly once
+	Rekey                 []string              // tables allowed to be copied to new key fields when their primaryKey or rangeKey changed
+	ToolVersion           string                // application version recorded in the _migrations ledger
+	LockTimeout           time.Duration         // how long to wait for another migration's advisory lock; zero waits indefinitely
+	AdditiveOnly          bool                  // if true, fail without applying anything when the plan contains a destructive action
+	Schema                string                // PostgreSQL schema to migrate, created if missing; empty uses the connection search path
+}
+
+// indexProgressInterval is how often progress is reported for concurrent index builds.
+const indexProgressInterval = 5 * time.Second
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// pendingIndex is an index whose build has been deferred until after the
+// migration transaction commits.
+type pendingIndex struct {
+	schema  string
+	table   config.TableConfig
+	index   config.IndexConfig
+	replace string // "invalid" or "drifted" when an existing index with the same name must be dropped first
 }
 
 // metaConfig is the JSON structure stored in the _meta table.
 type metaConfig struct {
 	PrimaryKeyField string `json:"primaryKeyField"`
 	RangeKeyField   string `json:"rangeKeyField"`
+	TenantColumn    bool   `json:"tenantColumn,omitempty"`
 }
 
 // Migrate creates or updates tables and indexes based on the provided configuration.
-// All operations run inside a single transaction.
+// All operations run inside a single transaction, except index builds when
+// opts.ConcurrentIndexes is set; those run afterwards, one at a time. Every
+// run that is not a dry run is recorded in the _migrations table. The whole run
+// holds a PostgreSQL advisory lock, so concurrent migrations are serialized.
 func Migrate(db *sql.DB, tables []config.TableConfig, opts MigrateOptions) error {
-	tx, err := db.Begin()
+	_, err := migrate(db, tables, opts, false)
+	return err
+}
+
+// PlanMigration computes the changes Migrate would make with the same options
+// without applying them. Nothing is logged; the plan is returned instead.
+func PlanMigration(db *sql.DB, tables []config.TableConfig, opts MigrateOptions) (*Plan, error) {
+	opts.DryRun = true
+	return migrate(db, tables, opts, true)
+}
+
+func migrate(db *sql.DB, tables []config.TableConfig, opts MigrateOptions, quiet bool) (*Plan, error) {
+	configHash, err := TablesConfigHash(tables)
 	if err != nil {
-		return fmt.Errorf("failed to begin migration transaction: %w", err)
+		return nil, fmt.Errorf("compute tables config hash: %w", err)
+	}
+
+	release, err := acquireMigrationLock(db, opts.LockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	sqlTx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin migration transaction: %w", err)
+	}
+	defer sqlTx.Rollback()
+
+	tx := &migrationTx{
+		Tx:     sqlTx,
+		dryRun: opts.DryRun,
+		quiet:  quiet,
+		schema: opts.Schema,
+		plan:   &Plan{ConfigHash: configHash, Actions: []PlanAction{}},
+	}
+
+	if opts.Schema != "" {
+		if err := useSchema(tx, opts.Schema); err != nil {
+			return nil, err
+		}
 	}
-	defer tx.Rollback()
 
-	if err := createMetaTable(tx, opts.DryRun); err != nil {
-		return fmt.Errorf("_meta table: %w", err)
+	if err := createMetaTable(tx); err != nil {
+		return nil, fmt.Errorf("_meta table: %w", err)
 	}
 
 	configuredTables := make(map[string]bool)
+	var pending []pendingIndex
 	for _, t := range tables {
 		configuredTables[t.Name] = true
 
-		if err := reconcileTable(tx, t, opts); err != nil {
-			return fmt.Errorf("table %q: %w", t.Name, err)
+		deferred, err := reconcileTable(tx, t, opts)
+		if err != nil {
+			return nil, fmt.Errorf("table %q: %w", t.Name, err)
 		}
+		pending = append(pending, deferred...)
+	}
+
+	if err := applyTransforms(tx, tables, opts.Transforms); err != nil {
+		return nil, fmt.Errorf("transforms: %w", err)
 	}
 
 	if opts.Cleanup {
-		if err := cleanupTables(tx, configuredTables, opts.DryRun); err != nil {
-			return fmt.Errorf("cleanup: %w", err)
+		if err := cleanupTables(tx, configuredTables); err != nil {
+			return nil, fmt.Errorf("cleanup: %w", err)
 		}
 	}
 
-	if err := upsertTablesConfigHash(tx, tables, opts.DryRun); err != nil {
-		return fmt.Errorf("store config hash: %w", err)
+	if opts.AdditiveOnly && tx.plan.Destructive {
+		return nil, fmt.Errorf("migration requires destructive changes (%s); run migrate explicitly", destructiveSummary(tx.plan))
 	}
 
 	if opts.DryRun {
-		return tx.Rollback()
+		tx.dryRunf("would upsert _meta config hash %q", configHash)
+		return tx.plan, sqlTx.Rollback()
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit migration transaction: %w", err)
+	// With concurrent index builds the config hash is stored only once every
+	// index exists, so the API does not start against a half-built schema.
+	if !opts.ConcurrentIndexes {
+		if err := upsertTablesConfigHash(tx, configHash); err != nil {
+			return nil, fmt.Errorf("store config hash: %w", err)
+		}
+		if err := recordMigration(tx, configHash, opts.ToolVersion, tx.statements); err != nil {
+			return nil, fmt.Errorf("record migration: %w", err)
+		}
 	}
-	return nil
+
+	if err := sqlTx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit migration transaction: %w", err)
+	}
+
+	if !opts.ConcurrentIndexes {
+		return tx.plan, nil
+	}
+
+	statements := tx.statements
+	for _, p := range pending {
+		stmts, err := buildIndexConcurrently(db, p)
+		statements = append(statements, stmts...)
+		if err != nil {
+			return nil, fmt.Errorf("table %q: index %q: %w", p.table.Name, p.index.Name, err)
+		}
+	}
+
+	if err := finishConcurrentMigration(db, opts.Schema, configHash, opts.ToolVersion, statements); err != nil {
+		return nil, err
+	}
+	return tx.plan, nil
 }
 
-func createMetaTable(tx *sql.Tx, dryRun bool) error {
+// finishConcurrentMigration stores the config hash and ledger entry once every
+// concurrent index build has completed.
+func finishConcurrentMigration(db *sql.DB, schema, configHash, toolVersion string, statements []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if schema != "" {
+		if _, err := tx.Exec(`SET LOCAL search_path TO ` + pq.QuoteIdentifier(schema)); err != nil {
+			return fmt.Errorf("setting search_path to %q: %w", schema, err)
+		}
+	}
+	if err := upsertTablesConfigHash(tx, configHash); err != nil {
+		return fmt.Errorf("store config hash: %w", err)
+	}
+	if err := recordMigration(tx, configHash, toolVersion, statements); err != nil {
+		return fmt.Errorf("record migration: %w", err)
+	}
+	return tx.Commit()
+}
+
+func createMetaTable(tx *migrationTx) error {
 	const stmt = `CREATE TABLE IF NOT EXISTS _meta (
 		table_name TEXT NOT NULL,
 		config JSONB NOT NULL,
@@ -73,19 +204,29 @@ func createMetaTable(tx *sql.Tx, dryRun bool) error {
 		PRIMARY KEY (table_name)
 	)`
 
-	if dryRun {
-		log.Printf("[dry-run] ensure _meta table exists")
-	}
+	tx.dryRunf("ensure _meta table exists")
 
 	if _, err := tx.Exec(stmt); err != nil {
 		return fmt.Errorf("failed to create _meta table: %w", err)
 	}
+	if _, err := tx.Exec(migrationsTableStatement); err != nil {
+		return fmt.Errorf("failed to create _migrations table: %w", err)
+	}
+	if _, err := tx.Exec(auditTableStatement); err != nil {
+		return fmt.Errorf("failed to create _audit table: %w", err)
+	}
+	for _, stmt := range auditIndexStatements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create _audit index: %w", err)
+		}
+	}
 	return nil
 }
 
 // reconcileTable handles creating or verifying a single table and its indexes.
-func reconcileTable(tx *sql.Tx, t config.TableConfig, opts MigrateOptions) error {
-	mc := metaConfig{PrimaryKeyField: t.PrimaryKey.Field}
+// It returns the index builds deferred for concurrent creation.
+func reconcileTable(tx *migrationTx, t config.TableConfig, opts MigrateOptions) ([]pendingIndex, error) {
+	mc := metaConfig{PrimaryKeyField: t.PrimaryKey.Field, TenantColumn: t.TenantClaim != ""}
 	if t.RangeKey != nil {
 		mc.RangeKeyField = t.RangeKey.Field
 	}
@@ -97,122 +238,201 @@ func reconcileTable(tx *sql.Tx, t config.TableConfig, opts MigrateOptions) error
 	switch {
 	case err == sql.ErrNoRows:
 		// New table
-		if opts.DryRun {
-			log.Printf("[dry-run] would create table %q", t.Name)
-		} else {
+		tx.record(PlanAction{
+			Action:      ActionCreateTable,
+			Table:       t.Name,
+			Description: fmt.Sprintf("create table %q with %s", t.Name, describeKeys(mc)),
+		})
+		if !opts.DryRun {
 			if err := createTable(tx, t); err != nil {
-				return err
+				return nil, err
 			}
 		}
 
 		configJSON, err := json.Marshal(mc)
 		if err != nil {
-			return fmt.Errorf("failed to marshal meta config: %w", err)
+			return nil, fmt.Errorf("failed to marshal meta config: %w", err)
 		}
-		if opts.DryRun {
-			log.Printf("[dry-run] would insert _meta entry for table %q", t.Name)
-		} else {
+		if !opts.DryRun {
 			if _, err := tx.Exec(
 				`INSERT INTO _meta (table_name, config) VALUES ($1, $2)`,
 				t.Name, configJSON,
 			); err != nil {
-				return fmt.Errorf("failed to insert _meta entry: %w", err)
+				return nil, fmt.Errorf("failed to insert _meta entry: %w", err)
 			}
 		}
 	case err != nil:
-		return fmt.Errorf("failed to query _meta: %w", err)
+		return nil, fmt.Errorf("failed to query _meta: %w", err)
 	default:
 		// Table exists in _meta — verify key fields haven't changed
 		var existing metaConfig
 		if err := json.Unmarshal(existingJSON, &existing); err != nil {
-			return fmt.Errorf("failed to parse _meta config: %w", err)
+			return nil, fmt.Errorf("failed to parse _meta config: %w", err)
 		}
-		if existing.PrimaryKeyField != mc.PrimaryKeyField {
-			return fmt.Errorf("primaryKey field changed from %q to %q; this is not allowed", existing.PrimaryKeyField, mc.PrimaryKeyField)
+		if existing.TenantColumn != mc.TenantColumn {
+			if mc.TenantColumn {
+				return nil, fmt.Errorf("tenantClaim cannot be added to an existing table: its items have no tenant")
+			}
+			return nil, fmt.Errorf("tenantClaim cannot be removed from an existing table: items of different tenants may share keys")
 		}
-		if existing.RangeKeyField != mc.RangeKeyField {
-			return fmt.Errorf("rangeKey field changed from %q to %q; this is not allowed", existing.RangeKeyField, mc.RangeKeyField)
+		if existing != mc {
+			if !slices.Contains(opts.Rekey, t.Name) {
+				if existing.PrimaryKeyField != mc.PrimaryKeyField {
+					return nil, fmt.Errorf("primaryKey field changed from %q to %q; rerun with -rekey %s to copy the table to the new keys", existing.PrimaryKeyField, mc.PrimaryKeyField, t.Name)
+				}
+				return nil, fmt.Errorf("rangeKey field changed from %q to %q; rerun with -rekey %s to copy the table to the new keys", existing.RangeKeyField, mc.RangeKeyField, t.Name)
+			}
+			if err := rekeyTable(tx, t, existing, mc); err != nil {
+				return nil, fmt.Errorf("rekey: %w", err)
+			}
+			if opts.DryRun {
+				// The old table and its indexes are still in place, so there is
+				// nothing meaningful to compare index definitions against: every
+				// configured index is built again on the new table.
+				for _, idx := range t.Indexes {
+					tx.record(indexAction(t, idx, "", opts))
+				}
+				return nil, nil
+			}
 		}
 	}
 
 	// Reconcile indexes
-	if err := reconcileIndexes(tx, t, opts); err != nil {
-		return fmt.Errorf("indexes: %w", err)
+	pending, err := reconcileIndexes(tx, t, opts)
+	if err != nil {
+		return nil, fmt.Errorf("indexes: %w", err)
 	}
 
-	return nil
+	return pending, nil
 }
 
 // reconcileIndexes creates new indexes and optionally removes stale ones.
-func reconcileIndexes(tx *sql.Tx, t config.TableConfig, opts MigrateOptions) error {
+// Indexes left INVALID by an interrupted concurrent build are rebuilt. When
+// opts.ConcurrentIndexes is set, builds are returned instead of executed.
+func reconcileIndexes(tx *migrationTx, t config.TableConfig, opts MigrateOptions) ([]pendingIndex, error) {
 	// Build set of desired index names
 	desired := make(map[string]bool)
 	for _, idx := range t.Indexes {
-		desired[fmt.Sprintf("idx_%s_%s", t.Name, idx.Name)] = true
+		desired[indexName(t, idx)] = true
 	}
 
-	// Create indexes that don't exist yet
+	// Create indexes that don't exist yet and replace invalid or drifted ones
+	var pending []pendingIndex
 	for _, idx := range t.Indexes {
-		idxName := fmt.Sprintf("idx_%s_%s", t.Name, idx.Name)
-
-		// Check if index already exists
-		var exists bool
-		err := tx.QueryRow(
-			`SELECT EXISTS(SELECT 1 FROM pg_indexes WHERE tablename = $1 AND indexname = $2)`,
-			t.Name, idxName,
-		).Scan(&exists)
+		idxName := indexName(t, idx)
+
+		existing, err := lookupIndex(tx, t.Name, idxName)
 		if err != nil {
-			return fmt.Errorf("checking index %q: %w", idxName, err)
+			return nil, fmt.Errorf("checking index %q: %w", idxName, err)
 		}
-		if exists {
-			continue
+
+		reason := ""
+		if existing != nil {
+			switch {
+			case !existing.valid:
+				reason = "invalid"
+			case indexDefinitionDrifted(existing.definition, idx):
+				if !opts.RebuildDriftedIndexes {
+					return nil, fmt.Errorf(
+						"index %q definition differs from config; rerun with -rebuild-drifted-indexes to drop and recreate it\n  database: %s\n  config:   %s",
+						idxName, existing.definition, indexStatement("", t, idx, false),
+					)
+				}
+				reason = "drifted"
+			default:
+				continue
+			}
 		}
 
+		tx.record(indexAction(t, idx, reason, opts))
+
 		if opts.DryRun {
-			log.Printf("[dry-run] would create index %q on table %q", idxName, t.Name)
-		} else {
-			if err := createIndex(tx, t, idx); err != nil {
-				return fmt.Errorf("index %q: %w", idx.Name, err)
+			continue
+		}
+		if opts.ConcurrentIndexes {
+			pending = append(pending, pendingIndex{schema: tx.schema, table: t, index: idx, replace: reason})
+			continue
+		}
+
+		if reason != "" {
+			slog.Info("dropping index", "reason", reason, "index", idxName, "table", t.Name)
+			if _, err := tx.Exec(fmt.Sprintf(`DROP INDEX IF EXISTS %q`, idxName)); err != nil {
+				return nil, fmt.Errorf("dropping %s index %q: %w", reason, idxName, err)
 			}
 		}
+		if err := createIndex(tx, t, idx); err != nil {
+			return nil, fmt.Errorf("index %q: %w", idx.Name, err)
+		}
 	}
 
 	// Cleanup stale indexes
 	if opts.Cleanup {
 		rows, err := tx.Query(
-			`SELECT indexname FROM pg_indexes WHERE tablename = $1 AND indexname LIKE 'idx_%'`,
+			`SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1 AND indexname LIKE 'idx_%'`,
 			t.Name,
 		)
 		if err != nil {
-			return fmt.Errorf("querying existing indexes: %w", err)
+			return nil, fmt.Errorf("querying existing indexes: %w", err)
 		}
 		defer rows.Close()
 
+		var toDrop []string
 		for rows.Next() {
 			var name string
 			if err := rows.Scan(&name); err != nil {
-				return fmt.Errorf("scanning index name: %w", err)
+				return nil, fmt.Errorf("scanning index name: %w", err)
 			}
 			if !desired[name] {
-				if opts.DryRun {
-					log.Printf("[dry-run] would drop index %q from table %q", name, t.Name)
-				} else {
-					if _, err := tx.Exec(fmt.Sprintf(`DROP INDEX IF EXISTS %q`, name)); err != nil {
-						return fmt.Errorf("dropping index %q: %w", name, err)
-					}
-				}
+				toDrop = append(toDrop, name)
 			}
 		}
 		if err := rows.Err(); err != nil {
-			return fmt.Errorf("iterating indexes: %w", err)
+			return nil, fmt.Errorf("iterating indexes: %w", err)
+		}
+		rows.Close()
+
+		for _, name := range toDrop {
+			tx.record(PlanAction{
+				Action:      ActionDropIndex,
+				Table:       t.Name,
+				Index:       name,
+				Description: fmt.Sprintf("drop index %q from table %q", name, t.Name),
+				Destructive: true,
+			})
+			if opts.DryRun {
+				continue
+			}
+			if _, err := tx.Exec(fmt.Sprintf(`DROP INDEX IF EXISTS %q`, name)); err != nil {
+				return nil, fmt.Errorf("dropping index %q: %w", name, err)
+			}
 		}
 	}
 
-	return nil
+	return pending, nil
+}
+
+// indexAction describes building idx, replacing an existing index with the
+// same name when reason is "invalid" or "drifted".
+func indexAction(t config.TableConfig, idx config.IndexConfig, reason string, opts MigrateOptions) PlanAction {
+	idxName := indexName(t, idx)
+	action := PlanAction{
+		Action:      ActionCreateIndex,
+		Table:       t.Name,
+		Index:       idx.Name,
+		Description: fmt.Sprintf("create index %q on table %q", idxName, t.Name),
+	}
+	if reason != "" {
+		action.Action = ActionRebuildIndex
+		action.Description = fmt.Sprintf("drop %s index %q from table %q and create it again", reason, idxName, t.Name)
+	}
+	if opts.ConcurrentIndexes {
+		action.Description += " concurrently"
+	}
+	return action
 }
 
 // cleanupTables drops tables in _meta that are not in the current config.
-func cleanupTables(tx *sql.Tx, configuredTables map[string]bool, dryRun bool) error {
+func cleanupTables(tx *migrationTx, configuredTables map[string]bool) error {
 	rows, err := tx.Query(`SELECT table_name FROM _meta`)
 	if err != nil {
 		return fmt.Errorf("querying _meta: %w", err)
@@ -225,7 +445,7 @@ func cleanupTables(tx *sql.Tx, configuredTables map[string]bool, dryRun bool) er
 		if err := rows.Scan(&name); err != nil {
 			return fmt.Errorf("scanning table name: %w", err)
 		}
-		if name == metaConfigHashRowName {
+		if name == metaConfigHashRowName || name == metaTransformsRowName {
 			continue
 		}
 		if !configuredTables[name] {
@@ -237,9 +457,13 @@ func cleanupTables(tx *sql.Tx, configuredTables map[string]bool, dryRun bool) er
 	}
 
 	for _, name := range toDrop {
-		if dryRun {
-			log.Printf("[dry-run] would drop table %q and remove _meta entry", name)
-		} else {
+		tx.record(PlanAction{
+			Action:      ActionDropTable,
+			Table:       name,
+			Description: fmt.Sprintf("drop table %q and remove _meta entry", name),
+			Destructive: true,
+		})
+		if !tx.dryRun {
 			if _, err := tx.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %q`, name)); err != nil {
 				return fmt.Errorf("dropping table %q: %w", name, err)
 			}
@@ -252,33 +476,40 @@ func cleanupTables(tx *sql.Tx, configuredTables map[string]bool, dryRun bool) er
 	return nil
 }
 
-func createTable(tx *sql.Tx, t config.TableConfig) error {
+func createTable(tx execer, t config.TableConfig) error {
+	// Row-level multi-tenant tables lead every key with the hidden tenant column,
+	// so items of different tenants may share keys.
+	tenantColumn, tenantKey := "", ""
+	if t.TenantClaim != "" {
+		tenantColumn, tenantKey = "tenant TEXT NOT NULL, ", "tenant, "
+	}
+
 	var stmt string
 	if t.RangeKey != nil {
 		// PK+RK table: rk is NOT NULL with composite primary key
 		stmt = fmt.Sprintf(
 			`CREATE TABLE IF NOT EXISTS %q (
-				pk TEXT NOT NULL,
+				%spk TEXT NOT NULL,
 				rk TEXT NOT NULL,
 				data JSONB NOT NULL,
 				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
 				updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-				PRIMARY KEY (pk, rk)
+				PRIMARY KEY (%spk, rk)
 			)`,
-			t.Name,
+			t.Name, tenantColumn, tenantKey,
 		)
 	} else {
 		// PK-only table: rk is nullable
 		stmt = fmt.Sprintf(
 			`CREATE TABLE IF NOT EXISTS %q (
-				pk TEXT NOT NULL,
+				%spk TEXT NOT NULL,
 				rk TEXT,
 				data JSONB NOT NULL,
 				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
 				updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-				PRIMARY KEY (pk)
+				PRIMARY KEY (%spk)
 			)`,
-			t.Name,
+			t.Name, tenantColumn, tenantKey,
 		)
 	}
 
@@ -288,33 +519,203 @@ func createTable(tx *sql.Tx, t config.TableConfig) error {
 	return nil
 }
 
-func createIndex(tx *sql.Tx, t config.TableConfig, idx config.IndexConfig) error {
+func createIndex(tx execer, t config.TableConfig, idx config.IndexConfig) error {
+	if _, err := tx.Exec(indexStatement("", t, idx, false)); err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+	return nil
+}
+
+// indexName returns the physical PostgreSQL index name for a configured index.
+func indexName(t config.TableConfig, idx config.IndexConfig) string {
+	return fmt.Sprintf("idx_%s_%s", t.Name, idx.Name)
+}
+
+// indexStatement builds the CREATE INDEX statement for a configured index. The
+// table is qualified with schema when it is set; the index is created in the
+// table's schema.
+func indexStatement(schema string, t config.TableConfig, idx config.IndexConfig, concurrently bool) string {
+	create := "CREATE INDEX"
+	if concurrently {
+		create = "CREATE INDEX CONCURRENTLY"
+	}
+	tenant := ""
+	if t.TenantClaim != "" {
+		tenant = "tenant, "
+	}
+
 	if idx.RangeKey != nil {
-		stmt := fmt.Sprintf(
-			`CREATE INDEX IF NOT EXISTS %q ON %q ((data->>%s), (data->>%s)) WHERE data->>%s IS NOT NULL AND data->>%s IS NOT NULL`,
-			fmt.Sprintf("idx_%s_%s", t.Name, idx.Name),
-			t.Name,
+		return fmt.Sprintf(
+			`%s IF NOT EXISTS %q ON %s (%s(data->>%s), (data->>%s)) WHERE data->>%s IS NOT NULL AND data->>%s IS NOT NULL`,
+			create,
+			indexName(t, idx),
+			qualifiedName(schema, t.Name),
+			tenant,
 			quoteStringLiteral(idx.PrimaryKey.Field),
 			quoteStringLiteral(idx.RangeKey.Field),
 			quoteStringLiteral(idx.PrimaryKey.Field),
 			quoteStringLiteral(idx.RangeKey.Field),
 		)
-		if _, err := tx.Exec(stmt); err != nil {
-			return fmt.Errorf("failed to create index: %w", err)
+	}
+	return fmt.Sprintf(
+		`%s IF NOT EXISTS %q ON %s (%s(data->>%s)) WHERE data->>%s IS NOT NULL`,
+		create,
+		indexName(t, idx),
+		qualifiedName(schema, t.Name),
+		tenant,
+		quoteStringLiteral(idx.PrimaryKey.Field),
+		quoteStringLiteral(idx.PrimaryKey.Field),
+	)
+}
+
+// existingIndex describes an index found in the PostgreSQL catalog.
+type existingIndex struct {
+	valid      bool
+	definition string
+}
+
+// lookupIndex returns the catalog state of the named index on the table, or
+// nil when it does not exist. A failed CREATE INDEX CONCURRENTLY leaves an
+// INVALID index behind that is never used by the planner.
+func lookupIndex(tx *migrationTx, table, idxName string) (*existingIndex, error) {
+	var idx existingIndex
+	err := tx.QueryRow(
+		`SELECT i.indisvalid, pg_get_indexdef(i.indexrelid)
+		 FROM pg_index i
+		 JOIN pg_class ic ON ic.oid = i.indexrelid
+		 JOIN pg_class tc ON tc.oid = i.indrelid
+		 WHERE tc.relname = $1 AND ic.relname = $2 AND tc.relnamespace = current_schema()::regnamespace`,
+		table, idxName,
+	).Scan(&idx.valid, &idx.definition)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &idx, nil
+}
+
+// indexFieldRegexp matches a JSONB text extraction as rendered by pg_get_indexdef,
+// for example (data ->> 'status'::text).
+var indexFieldRegexp = regexp.MustCompile(`data ->> '((?:[^']|'')*)'::text`)
+
+// indexDefinitionFields extracts the JSONB fields used as index key columns and
+// the fields referenced by the partial index predicate from a pg_get_indexdef result.
+func indexDefinitionFields(definition string) (keys []string, predicate []string) {
+	keyPart, predicatePart, _ := strings.Cut(definition, " WHERE ")
+	for _, m := range indexFieldRegexp.FindAllStringSubmatch(keyPart, -1) {
+		keys = append(keys, strings.ReplaceAll(m[1], "''", "'"))
+	}
+	for _, m := range indexFieldRegexp.FindAllStringSubmatch(predicatePart, -1) {
+		predicate = append(predicate, strings.ReplaceAll(m[1], "''", "'"))
+	}
+	return keys, predicate
+}
+
+// indexDefinitionDrifted reports whether an existing index definition uses
+// different key fields, or a different sparse predicate, than the configured index.
+func indexDefinitionDrifted(definition string, idx config.IndexConfig) bool {
+	want := []string{idx.PrimaryKey.Field}
+	if idx.RangeKey != nil {
+		want = append(want, idx.RangeKey.Field)
+	}
+
+	keys, predicate := indexDefinitionFields(definition)
+	if !slices.Equal(keys, want) {
+		return true
+	}
+
+	slices.Sort(predicate)
+	predicate = slices.Compact(predicate)
+	wantPredicate := slices.Clone(want)
+	slices.Sort(wantPredicate)
+	return !slices.Equal(predicate, wantPredicate)
+}
+
+// buildIndexConcurrently builds a single index with CREATE INDEX CONCURRENTLY,
+// dropping a leftover INVALID or drifted index first. Progress is logged from
+// pg_stat_progress_create_index while the build runs. It returns the statements
+// that were executed.
+func buildIndexConcurrently(db *sql.DB, p pendingIndex) ([]string, error) {
+	ctx := context.Background()
+	idxName := indexName(p.table, p.index)
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	var executed []string
+	if p.replace != "" {
+		slog.Info("dropping index", "reason", p.replace, "index", idxName, "table", p.table.Name)
+		stmt := fmt.Sprintf(`DROP INDEX CONCURRENTLY IF EXISTS %s`, qualifiedName(p.schema, idxName))
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return executed, fmt.Errorf("dropping %s index: %w", p.replace, err)
 		}
-	} else {
-		stmt := fmt.Sprintf(
-			`CREATE INDEX IF NOT EXISTS %q ON %q ((data->>%s)) WHERE data->>%s IS NOT NULL`,
-			fmt.Sprintf("idx_%s_%s", t.Name, idx.Name),
-			t.Name,
-			quoteStringLiteral(idx.PrimaryKey.Field),
-			quoteStringLiteral(idx.PrimaryKey.Field),
-		)
-		if _, err := tx.Exec(stmt); err != nil {
-			return fmt.Errorf("failed to create index: %w", err)
+		executed = append(executed, stmt)
+	}
+
+	var pid int
+	if err := conn.QueryRowContext(ctx, `SELECT pg_backend_pid()`).Scan(&pid); err != nil {
+		return executed, fmt.Errorf("reading backend pid: %w", err)
+	}
+
+	slog.Info("creating index concurrently", "index", idxName, "table", p.table.Name)
+	done := make(chan struct{})
+	go reportIndexProgress(db, pid, idxName, done)
+
+	start := time.Now()
+	stmt := indexStatement(p.schema, p.table, p.index, true)
+	_, err = conn.ExecContext(ctx, stmt)
+	close(done)
+	if err != nil {
+		return executed, fmt.Errorf("failed to create index concurrently (an INVALID index may remain and will be rebuilt on the next run): %w", err)
+	}
+	executed = append(executed, stmt)
+
+	slog.Info("created index", "index", idxName, "table", p.table.Name, "duration", time.Since(start).Round(time.Millisecond))
+	return executed, nil
+}
+
+// reportIndexProgress logs the build phase and progress counters for the
+// backend building an index until done is closed.
+func reportIndexProgress(db *sql.DB, pid int, idxName string, done <-chan struct{}) {
+	ticker := time.NewTicker(indexProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
 		}
+
+		var phase string
+		var blocksTotal, blocksDone, tuplesTotal, tuplesDone int64
+		err := db.QueryRow(
+			`SELECT phase, blocks_total, blocks_done, tuples_total, tuples_done
+			 FROM pg_stat_progress_create_index WHERE pid = $1`,
+			pid,
+		).Scan(&phase, &blocksTotal, &blocksDone, &tuplesTotal, &tuplesDone)
+		if err != nil {
+			continue
+		}
+		slog.Info("index build progress", "index", idxName, "progress", formatIndexProgress(phase, blocksTotal, blocksDone, tuplesTotal, tuplesDone))
+	}
+}
+
+// formatIndexProgress renders a pg_stat_progress_create_index row for logging.
+func formatIndexProgress(phase string, blocksTotal, blocksDone, tuplesTotal, tuplesDone int64) string {
+	switch {
+	case blocksTotal > 0:
+		return fmt.Sprintf("%s (blocks %d/%d, %.1f%%)", phase, blocksDone, blocksTotal, float64(blocksDone)*100/float64(blocksTotal))
+	case tuplesTotal > 0:
+		return fmt.Sprintf("%s (tuples %d/%d, %.1f%%)", phase, tuplesDone, tuplesTotal, float64(tuplesDone)*100/float64(tuplesTotal))
+	default:
+		return phase
 	}
-	return nil
 }
 
 // quoteStringLiteral wraps a value in single quotes for use as a SQL string literal.
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+)
+
+func TestIndexStatement(t *testing.T) {
+	table := config.TableConfig{Name: "orders"}
+
+	pkOnly := config.IndexConfig{
+		Name:       "by_customer",
+		PrimaryKey: config.KeyConfig{Field: "customerId"},
+	}
+	withRK := config.IndexConfig{
+		Name:       "by_status",
+		PrimaryKey: config.KeyConfig{Field: "status"},
+		RangeKey:   &config.KeyConfig{Field: "product"},
+	}
+
+	tests := []struct {
+		name         string
+		schema       string
+		tenantClaim  string
+		idx          config.IndexConfig
+		concurrently bool
+		want         string
+	}{
+		{
+			name: "pk only",
+			idx:  pkOnly,
+			want: `CREATE INDEX IF NOT EXISTS "idx_orders_by_customer" ON "orders" ((data->>'customerId')) WHERE data->>'customerId' IS NOT NULL`,
+		},
+		{
+			name:         "pk only concurrently",
+			idx:          pkOnly,
+			concurrently: true,
+			want:         `CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_orders_by_customer" ON "orders" ((data->>'customerId')) WHERE data->>'customerId' IS NOT NULL`,
+		},
+		{
+			name:         "pk and rk concurrently",
+			idx:          withRK,
+			concurrently: true,
+			want:         `CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_orders_by_status" ON "orders" ((data->>'status'), (data->>'product')) WHERE data->>'status' IS NOT NULL AND data->>'product' IS NOT NULL`,
+		},
+		{
+			name:         "schema qualified",
+			schema:       "tenant_acme",
+			idx:          pkOnly,
+			concurrently: true,
+			want:         `CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_orders_by_customer" ON "tenant_acme"."orders" ((data->>'customerId')) WHERE data->>'customerId' IS NOT NULL`,
+		},
+		{
+			name:        "tenant scoped",
+			tenantClaim: "org",
+			idx:         withRK,
+			want:        `CREATE INDEX IF NOT EXISTS "idx_orders_by_status" ON "orders" (tenant, (data->>'status'), (data->>'product')) WHERE data->>'status' IS NOT NULL AND data->>'product' IS NOT NULL`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := table
+			table.TenantClaim = tt.tenantClaim
+			got := indexStatement(tt.schema, table, tt.idx, tt.concurrently)
+			if got != tt.want {
+				t.Fatalf("unexpected statement:\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatIndexProgress(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{
+			name: "blocks",
+			got:  formatIndexProgress("building index: scanning table", 200, 50, 0, 0),
+			want: "building index: scanning table (blocks 50/200, 25.0%)",
+		},
+		{
+			name: "tuples",
+			got:  formatIndexProgress("building index: loading tuples in tree", 0, 0, 1000, 999),
+			want: "building index: loading tuples in tree (tuples 999/1000, 99.9%)",
+		},
+		{
+			name: "phase only",
+			got:  formatIndexProgress("waiting for writers before build", 0, 0, 0, 0),
+			want: "waiting for writers before build",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, tt.got)
+			}
+		})
+	}
+}
+
+func TestIndexDefinitionDrifted(t *testing.T) {
+	pkOnly := config.IndexConfig{
+		Name:       "by_customer",
+		PrimaryKey: config.KeyConfig{Field: "customerId"},
+	}
+	withRK := config.IndexConfig{
+		Name:       "by_status",
+		PrimaryKey: config.KeyConfig{Field: "status"},
+		RangeKey:   &config.KeyConfig{Field: "product"},
+	}
+
+	const pkOnlyDef = `CREATE INDEX idx_orders_by_customer ON public.orders USING btree (((data ->> 'customerId'::text))) WHERE ((data ->> 'customerId'::text) IS NOT NULL)`
+	const withRKDef = `CREATE INDEX idx_orders_by_status ON public.orders USING btree (((data ->> 'status'::text)), ((data ->> 'product'::text))) WHERE (((data ->> 'status'::text) IS NOT NULL) AND ((data ->> 'product'::text) IS NOT NULL))`
+
+	tests := []struct {
+		name       string
+		definition string
+		idx        config.IndexConfig
+		want       bool
+	}{
+		{"pk only matches", pkOnlyDef, pkOnly, false},
+		{"pk and rk matches", withRKDef, withRK, false},
+		{"pk field changed", pkOnlyDef, config.IndexConfig{PrimaryKey: config.KeyConfig{Field: "accountId"}}, true},
+		{"rk added", pkOnlyDef, config.IndexConfig{PrimaryKey: config.KeyConfig{Field: "customerId"}, RangeKey: &config.KeyConfig{Field: "createdAt"}}, true},
+		{"rk removed", withRKDef, config.IndexConfig{PrimaryKey: config.KeyConfig{Field: "status"}}, true},
+		{"key order swapped", withRKDef, config.IndexConfig{PrimaryKey: config.KeyConfig{Field: "product"}, RangeKey: &config.KeyConfig{Field: "status"}}, true},
+		{"missing predicate", `CREATE INDEX idx_orders_by_customer ON public.orders USING btree (((data ->> 'customerId'::text)))`, pkOnly, true},
+		{"not an expression index", `CREATE INDEX idx_orders_by_customer ON public.orders USING btree (pk)`, pkOnly, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := indexDefinitionDrifted(tt.definition, tt.idx); got != tt.want {
+				t.Fatalf("expected drifted=%v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDestructiveSummary(t *testing.T) {
+	p := &Plan{Actions: []PlanAction{
+		{Action: ActionCreateIndex, Description: `create index "idx_items_by_status" on table "items"`},
+		{Action: ActionDropIndex, Description: `drop index "idx_items_old" from table "items"`, Destructive: true},
+		{Action: ActionDropTable, Description: `drop table "legacy" and remove _meta entry`, Destructive: true},
+	}}
+
+	got := destructiveSummary(p)
+	want := `drop index "idx_items_old" from table "items"; drop table "legacy" and remove _meta entry`
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestIndexAction(t *testing.T) {
+	table := config.TableConfig{Name: "items"}
+	idx := config.IndexConfig{Name: "by_status", PrimaryKey: config.KeyConfig{Field: "status"}}
+
+	tests := []struct {
+		name   string
+		reason string
+		opts   MigrateOptions
+		want   PlanAction
+	}{
+		{
+			name: "create",
+			want: PlanAction{Action: ActionCreateIndex, Table: "items", Index: "by_status", Description: `create index "idx_items_by_status" on table "items"`},
+		},
+		{
+			name: "create concurrently",
+			opts: MigrateOptions{ConcurrentIndexes: true},
+			want: PlanAction{Action: ActionCreateIndex, Table: "items", Index: "by_status", Description: `create index "idx_items_by_status" on table "items" concurrently`},
+		},
+		{
+			name:   "rebuild drifted",
+			reason: "drifted",
+			want:   PlanAction{Action: ActionRebuildIndex, Table: "items", Index: "by_status", Description: `drop drifted index "idx_items_by_status" from table "items" and create it again`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := indexAction(table, idx, tt.reason, tt.opts); got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Plan action types.
+const (
+	ActionCreateSchema   = "create_schema"
+	ActionCreateTable    = "create_table"
+	ActionRekeyTable     = "rekey_table"
+	ActionDropTable      = "drop_table"
+	ActionCreateIndex    = "create_index"
+	ActionRebuildIndex   = "rebuild_index"
+	ActionDropIndex      = "drop_index"
+	ActionApplyTransform = "apply_transform"
+)
+
+// Plan lists the schema and data changes a migration makes, or would make
+// during a dry run.
+type Plan struct {
+	ConfigHash  string       `json:"configHash"`
+	Destructive bool         `json:"destructive"`
+	Actions     []PlanAction `json:"actions"`
+}
+
+// PlanAction is a single planned change. Destructive actions drop tables,
+// indexes, or stored attributes.
+type PlanAction struct {
+	Action      string `json:"action"`
+	Table       string `json:"table,omitempty"`
+	Index       string `json:"index,omitempty"`
+	Description string `json:"description"`
+	Destructive bool   `json:"destructive"`
+}
+
+// migrationTx wraps the migration transaction. It records every executed
+// statement for the _migrations ledger and every planned action for the plan.
+type migrationTx struct {
+	*sql.Tx
+	schema     string // schema the search path points at; empty for the default
+	dryRun     bool
+	quiet      bool // suppress [dry-run] log lines when the plan is returned instead
+	plan       *Plan
+	statements []string
+}
+
+func (m *migrationTx) Exec(query string, args ...any) (sql.Result, error) {
+	res, err := m.Tx.Exec(query, args...)
+	if err == nil {
+		m.statements = append(m.statements, query)
+	}
+	return res, err
+}
+
+// record adds an action to the plan and logs it during a dry run.
+func (m *migrationTx) record(a PlanAction) {
+	m.plan.Actions = append(m.plan.Actions, a)
+	if a.Destructive {
+		m.plan.Destructive = true
+	}
+	m.dryRunf("would %s", a.Description)
+}
+
+// dryRunf logs a dry-run action unless the plan is being returned instead.
+func (m *migrationTx) dryRunf(format string, args ...any) {
+	if m.dryRun && !m.quiet {
+		slog.Info("dry run: " + fmt.Sprintf(format, args...))
+	}
+}
+
+// destructiveSummary joins the descriptions of the plan's destructive actions.
+func destructiveSummary(p *Plan) string {
+	var parts []string
+	for _, a := range p.Actions {
+		if a.Destructive {
+			parts = append(parts, a.Description)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
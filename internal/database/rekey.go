@@ -0,0 +1,237 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/lib/pq"
+)
+
+// rekeyTable rebuilds a table whose key fields changed. Rows are copied into a
+// new physical table with pk/rk re-derived from the stored data (or from the
+// old key columns), the new table replaces the old one, and the _meta entry is
+// updated. Indexes are left for reconcileIndexes to rebuild.
+func rekeyTable(tx *migrationTx, t config.TableConfig, old metaConfig, next metaConfig) error {
+	newPK := rekeyKeyExpr(old, next.PrimaryKeyField)
+	newRK := "NULL"
+	if next.RangeKeyField != "" {
+		newRK = rekeyKeyExpr(old, next.RangeKeyField)
+	}
+
+	if err := checkRekeyable(tx, t.Name, newPK, newRK, next); err != nil {
+		return err
+	}
+
+	tx.record(PlanAction{
+		Action:      ActionRekeyTable,
+		Table:       t.Name,
+		Description: fmt.Sprintf("rekey table %q from %s to %s, replacing the physical table", t.Name, describeKeys(old), describeKeys(next)),
+		Destructive: true,
+	})
+	if err := recordDroppedIndexes(tx, t); err != nil {
+		return err
+	}
+	if tx.dryRun {
+		return nil
+	}
+
+	slog.Info("rekeying table", "table", t.Name, "from", describeKeys(old), "to", describeKeys(next))
+
+	tmp := t
+	tmp.Name = t.Name + "__rekey"
+	var conflict sql.NullString
+	if err := tx.QueryRow(`SELECT to_regclass($1)::text`, pq.QuoteIdentifier(tmp.Name)).Scan(&conflict); err != nil {
+		return fmt.Errorf("checking for %q: %w", tmp.Name, err)
+	}
+	if conflict.Valid {
+		return fmt.Errorf("cannot rekey: table %q already exists", tmp.Name)
+	}
+	if err := createTable(tx, tmp); err != nil {
+		return err
+	}
+
+	copied, err := copyRekeyedRows(tx, t.Name, tmp.Name, old, newPK, newRK, rekeyDataExpr(old, next))
+	if err != nil {
+		return err
+	}
+
+	// PostgreSQL truncates generated constraint names to 63 bytes, so the
+	// primary keys are renamed by their actual names rather than derived ones.
+	oldPKey, err := primaryKeyName(tx, t.Name)
+	if err != nil {
+		return err
+	}
+	tmpPKey, err := primaryKeyName(tx, tmp.Name)
+	if err != nil {
+		return err
+	}
+	stmts := []string{
+		fmt.Sprintf(`DROP TABLE %q`, t.Name),
+		fmt.Sprintf(`ALTER TABLE %q RENAME TO %q`, tmp.Name, t.Name),
+		fmt.Sprintf(`ALTER TABLE %q RENAME CONSTRAINT %s TO %s`, t.Name, pq.QuoteIdentifier(tmpPKey), pq.QuoteIdentifier(oldPKey)),
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("swapping rekeyed table: %w", err)
+		}
+	}
+
+	configJSON, err := json.Marshal(next)
+	if err != nil {
+		return fmt.Errorf("failed to marshal meta config: %w", err)
+	}
+	if _, err := tx.Exec(
+		`UPDATE _meta SET config = $2, updated_at = now() WHERE table_name = $1`,
+		t.Name, configJSON,
+	); err != nil {
+		return fmt.Errorf("failed to update _meta entry: %w", err)
+	}
+
+	slog.Info("rekeyed table", "table", t.Name, "copied", copied)
+	return nil
+}
+
+// primaryKeyName returns the name of the primary key constraint of table.
+func primaryKeyName(tx *migrationTx, table string) (string, error) {
+	var name string
+	if err := tx.QueryRow(
+		`SELECT conname FROM pg_constraint WHERE conrelid = $1::regclass AND contype = 'p'`,
+		pq.QuoteIdentifier(table),
+	).Scan(&name); err != nil {
+		return "", fmt.Errorf("looking up the primary key of %q: %w", table, err)
+	}
+	return name, nil
+}
+
+// recordDroppedIndexes records the indexes of the old physical table that are
+// no longer configured; they are dropped with it and not rebuilt.
+func recordDroppedIndexes(tx *migrationTx, t config.TableConfig) error {
+	desired := make(map[string]bool)
+	for _, idx := range t.Indexes {
+		desired[indexName(t, idx)] = true
+	}
+
+	rows, err := tx.Query(
+		`SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1 AND indexname LIKE 'idx_%' ORDER BY indexname`,
+		t.Name,
+	)
+	if err != nil {
+		return fmt.Errorf("querying existing indexes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("scanning index name: %w", err)
+		}
+		if desired[name] {
+			continue
+		}
+		tx.record(PlanAction{
+			Action:      ActionDropIndex,
+			Table:       t.Name,
+			Index:       name,
+			Description: fmt.Sprintf("drop index %q with the old physical table of %q", name, t.Name),
+			Destructive: true,
+		})
+	}
+	return rows.Err()
+}
+
+// checkRekeyable verifies every row yields a non-null, unique new key.
+func checkRekeyable(tx *migrationTx, table, newPK, newRK string, next metaConfig) error {
+	var missingPK, missingRK int
+	if err := tx.QueryRow(fmt.Sprintf(
+		`SELECT count(*) FILTER (WHERE %s IS NULL), count(*) FILTER (WHERE %s IS NULL) FROM %q`,
+		newPK, newRK, table,
+	)).Scan(&missingPK, &missingRK); err != nil {
+		return fmt.Errorf("checking new key values: %w", err)
+	}
+	if missingPK > 0 {
+		return fmt.Errorf("cannot rekey: %d item(s) have no value for new primaryKey field %q", missingPK, next.PrimaryKeyField)
+	}
+	if next.RangeKeyField != "" && missingRK > 0 {
+		return fmt.Errorf("cannot rekey: %d item(s) have no value for new rangeKey field %q", missingRK, next.RangeKeyField)
+	}
+
+	groupBy := newPK
+	if next.TenantColumn {
+		groupBy = "tenant, " + groupBy
+	}
+	if next.RangeKeyField != "" {
+		groupBy += ", " + newRK
+	}
+	var duplicates int
+	if err := tx.QueryRow(fmt.Sprintf(
+		`SELECT count(*) FROM (SELECT 1 FROM %q GROUP BY %s HAVING count(*) > 1) d`,
+		table, groupBy,
+	)).Scan(&duplicates); err != nil {
+		return fmt.Errorf("checking new key uniqueness: %w", err)
+	}
+	if duplicates > 0 {
+		return fmt.Errorf("cannot rekey: %d new key value(s) are shared by more than one item", duplicates)
+	}
+	return nil
+}
+
+// copyRekeyedRows copies every row from src to dst. The copy runs in the
+// migration transaction, so splitting it into batches would not release any
+// locks or old row versions before the commit.
+func copyRekeyedRows(tx *migrationTx, src, dst string, old metaConfig, newPK, newRK, newData string) (int64, error) {
+	columns, values := "pk, rk, data, created_at, updated_at", fmt.Sprintf("%s, %s, %s, created_at, updated_at", newPK, newRK, newData)
+	if old.TenantColumn {
+		columns, values = "tenant, "+columns, "tenant, "+values
+	}
+
+	res, err := tx.Exec(fmt.Sprintf(`INSERT INTO %q (%s) SELECT %s FROM %q`, dst, columns, values, src))
+	if err != nil {
+		return 0, fmt.Errorf("copying rows: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// rekeyKeyExpr returns the SQL expression that yields a new key value from an
+// old row: the old key column when the field was a key before, otherwise the
+// stored attribute.
+func rekeyKeyExpr(old metaConfig, field string) string {
+	switch field {
+	case old.PrimaryKeyField:
+		return "pk"
+	case old.RangeKeyField:
+		return "rk"
+	default:
+		return fmt.Sprintf("data->>%s", quoteStringLiteral(field))
+	}
+}
+
+// rekeyDataExpr returns the SQL expression for the new data column: old key
+// fields are moved back into the payload and new key fields are removed.
+func rekeyDataExpr(old metaConfig, next metaConfig) string {
+	expr := "data"
+	if !isKeyField(next, old.PrimaryKeyField) {
+		expr = fmt.Sprintf("(%s || jsonb_build_object(%s, pk))", expr, quoteStringLiteral(old.PrimaryKeyField))
+	}
+	if old.RangeKeyField != "" && !isKeyField(next, old.RangeKeyField) {
+		expr = fmt.Sprintf("(%s || jsonb_build_object(%s, rk))", expr, quoteStringLiteral(old.RangeKeyField))
+	}
+	expr = fmt.Sprintf("%s - %s", expr, quoteStringLiteral(next.PrimaryKeyField))
+	if next.RangeKeyField != "" {
+		expr = fmt.Sprintf("%s - %s", expr, quoteStringLiteral(next.RangeKeyField))
+	}
+	return expr
+}
+
+func isKeyField(mc metaConfig, field string) bool {
+	return field == mc.PrimaryKeyField || (mc.RangeKeyField != "" && field == mc.RangeKeyField)
+}
+
+func describeKeys(mc metaConfig) string {
+	if mc.RangeKeyField == "" {
+		return fmt.Sprintf("primaryKey %q", mc.PrimaryKeyField)
+	}
+	return fmt.Sprintf("primaryKey %q, rangeKey %q", mc.PrimaryKeyField, mc.RangeKeyField)
+}
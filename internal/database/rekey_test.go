@@ -0,0 +1,64 @@
+package database
+
+import "testing"
+
+func TestRekeyKeyExpr(t *testing.T) {
+	old := metaConfig{PrimaryKeyField: "orderId", RangeKeyField: "lineId"}
+
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"orderId", "pk"},
+		{"lineId", "rk"},
+		{"customerId", "data->>'customerId'"},
+	}
+
+	for _, tt := range tests {
+		if got := rekeyKeyExpr(old, tt.field); got != tt.want {
+			t.Errorf("rekeyKeyExpr(%q): expected %q, got %q", tt.field, tt.want, got)
+		}
+	}
+}
+
+func TestRekeyDataExpr(t *testing.T) {
+	tests := []struct {
+		name string
+		old  metaConfig
+		next metaConfig
+		want string
+	}{
+		{
+			name: "replace range key",
+			old:  metaConfig{PrimaryKeyField: "orderId", RangeKeyField: "lineId"},
+			next: metaConfig{PrimaryKeyField: "orderId", RangeKeyField: "sku"},
+			want: "(data || jsonb_build_object('lineId', rk)) - 'orderId' - 'sku'",
+		},
+		{
+			name: "swap keys",
+			old:  metaConfig{PrimaryKeyField: "orderId", RangeKeyField: "lineId"},
+			next: metaConfig{PrimaryKeyField: "lineId", RangeKeyField: "orderId"},
+			want: "data - 'lineId' - 'orderId'",
+		},
+		{
+			name: "add range key",
+			old:  metaConfig{PrimaryKeyField: "userId"},
+			next: metaConfig{PrimaryKeyField: "tenantId", RangeKeyField: "userId"},
+			want: "data - 'tenantId' - 'userId'",
+		},
+		{
+			name: "drop range key",
+			old:  metaConfig{PrimaryKeyField: "orderId", RangeKeyField: "lineId"},
+			next: metaConfig{PrimaryKeyField: "lineUid"},
+			want: "((data || jsonb_build_object('orderId', pk)) || jsonb_build_object('lineId', rk)) - 'lineUid'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rekeyDataExpr(tt.old, tt.next); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
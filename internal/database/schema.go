@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// ErrSchemaNotFound is returned by RequireSchema for a schema that does not
+// exist.
+var ErrSchemaNotFound = errors.New("schema does not exist")
+
+type schemaContextKey struct{}
+
+// WithSchema returns a context whose Store operations target tables in the
+// given PostgreSQL schema instead of the Store's default schema.
+func WithSchema(ctx context.Context, schema string) context.Context {
+	return context.WithValue(ctx, schemaContextKey{}, schema)
+}
+
+// RequireSchema returns an error wrapping ErrSchemaNotFound when schema does
+// not exist.
+func RequireSchema(ctx context.Context, db *sql.DB, schema string) error {
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)`, schema).Scan(&exists); err != nil {
+		return fmt.Errorf("checking schema %q: %w", schema, err)
+	}
+	if !exists {
+		return fmt.Errorf("schema %q: %w", schema, ErrSchemaNotFound)
+	}
+	return nil
+}
+
+// qualifiedName quotes a table or index name, prefixed with its schema when
+// one is set. Without a schema the name resolves through the search path.
+func qualifiedName(schema, name string) string {
+	if schema == "" {
+		return pq.QuoteIdentifier(name)
+	}
+	return pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(name)
+}
+
+// useSchema creates the schema if needed and points the migration
+// transaction's search path at it, so every unqualified table, index and
+// _meta reference in the migration resolves inside that schema.
+func useSchema(tx *migrationTx, schema string) error {
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)`, schema).Scan(&exists); err != nil {
+		return fmt.Errorf("checking schema %q: %w", schema, err)
+	}
+	if !exists {
+		tx.record(PlanAction{
+			Action:      ActionCreateSchema,
+			Description: fmt.Sprintf("create schema %q", schema),
+		})
+		if _, err := tx.Exec(`CREATE SCHEMA IF NOT EXISTS ` + pq.QuoteIdentifier(schema)); err != nil {
+			return fmt.Errorf("creating schema %q: %w", schema, err)
+		}
+	}
+
+	if _, err := tx.Exec(`SET LOCAL search_path TO ` + pq.QuoteIdentifier(schema)); err != nil {
+		return fmt.Errorf("setting search_path to %q: %w", schema, err)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package database
+
+import "testing"
+
+func TestQualifiedName(t *testing.T) {
+	tests := []struct {
+		schema, name, want string
+	}{
+		{"", "items", `"items"`},
+		{"app", "items", `"app"."items"`},
+		{"app_acme", `we"ird`, `"app_acme"."we""ird"`},
+		{`a\b`, "items", `"a\b"."items"`},
+	}
+	for _, tt := range tests {
+		if got := qualifiedName(tt.schema, tt.name); got != tt.want {
+			t.Errorf("qualifiedName(%q, %q) = %s, want %s", tt.schema, tt.name, got, tt.want)
+		}
+	}
+}
@@ -7,7 +7,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // cursor is the internal representation of a pagination cursor.
@@ -18,7 +22,10 @@ type cursor struct {
 
 // Store provides CRUD operations against PostgreSQL tables.
 type Store struct {
-	db *sql.DB
+	db     *sql.DB
+	schema string // default schema; empty uses the connection search path
+
+	slowQuery atomic.Int64 // slow query threshold in nanoseconds; zero disables the log
 }
 
 // NewStore creates a new Store backed by the given database connection.
@@ -26,6 +33,21 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
+// NewStoreWithSchema creates a new Store whose tables live in the named
+// PostgreSQL schema. A schema set on the request context with WithSchema
+// takes precedence.
+func NewStoreWithSchema(db *sql.DB, schema string) *Store {
+	return &Store{db: db, schema: schema}
+}
+
+// table returns the quoted, schema-qualified name of a table for this request.
+func (s *Store) table(ctx context.Context, name string) string {
+	if schema, ok := ctx.Value(schemaContextKey{}).(string); ok {
+		return qualifiedName(schema, name)
+	}
+	return qualifiedName(s.schema, name)
+}
+
 // ListOptions controls pagination and range-key filtering for list/scan/query operations.
 type ListOptions struct {
 	Limit        int
@@ -35,6 +57,7 @@ type ListOptions struct {
 	RKGte        string
 	RKLt         string
 	RKLte        string
+	Explain      bool // return the query plan instead of items
 }
 
 // ItemResult holds a single item with its base table keys.
@@ -53,12 +76,14 @@ type ItemForUpdate struct {
 // ListResult holds a page of items and optional page tokens for pagination.
 type ListResult struct {
 	Items             []ItemResult
-	NextPageToken     string // empty if no more pages
-	PreviousPageToken string // reserved for future use
+	NextPageToken     string     // empty if no more pages
+	PreviousPageToken string     // reserved for future use
+	Plan              *QueryPlan // set instead of Items when ListOptions.Explain is set
 }
 
 // IndexQueryConfig describes the key fields for a GSI query.
 type IndexQueryConfig struct {
+	Name    string // index name, recorded on query spans
 	PKField string
 	RKField string // empty if pk-only GSI
 }
@@ -81,21 +106,11 @@ func decodeCursor(s string) (cursor, error) {
 
 // GetItem retrieves a single item by PK (and optionally RK).
 func (s *Store) GetItem(ctx context.Context, table string, pk string, rk *string) (map[string]any, error) {
-	var row *sql.Row
-	if rk != nil {
-		row = s.db.QueryRowContext(ctx,
-			fmt.Sprintf(`SELECT data FROM %q WHERE pk = $1 AND rk = $2`, table),
-			pk, *rk,
-		)
-	} else {
-		row = s.db.QueryRowContext(ctx,
-			fmt.Sprintf(`SELECT data FROM %q WHERE pk = $1`, table),
-			pk,
-		)
-	}
+	where, args := itemKeyFilter(ctx, pk, rk)
+	query := fmt.Sprintf(`SELECT data FROM %s WHERE %s`, s.table(ctx, table), where)
 
 	var dataBytes []byte
-	if err := row.Scan(&dataBytes); err != nil {
+	if err := s.queryRow(ctx, "GetItem", table, "", query, args, &dataBytes); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -111,22 +126,12 @@ func (s *Store) GetItem(ctx context.Context, table string, pk string, rk *string
 
 // GetItemForUpdate retrieves an item along with its updated_at timestamp.
 func (s *Store) GetItemForUpdate(ctx context.Context, table string, pk string, rk *string) (*ItemForUpdate, error) {
-	var row *sql.Row
-	if rk != nil {
-		row = s.db.QueryRowContext(ctx,
-			fmt.Sprintf(`SELECT data, updated_at FROM %q WHERE pk = $1 AND rk = $2`, table),
-			pk, *rk,
-		)
-	} else {
-		row = s.db.QueryRowContext(ctx,
-			fmt.Sprintf(`SELECT data, updated_at FROM %q WHERE pk = $1`, table),
-			pk,
-		)
-	}
+	where, args := itemKeyFilter(ctx, pk, rk)
+	query := fmt.Sprintf(`SELECT data, updated_at FROM %s WHERE %s`, s.table(ctx, table), where)
 
 	var dataBytes []byte
 	var updatedAt time.Time
-	if err := row.Scan(&dataBytes, &updatedAt); err != nil {
+	if err := s.queryRow(ctx, "GetItemForUpdate", table, "", query, args, &dataBytes, &updatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -152,33 +157,48 @@ func (s *Store) PutItem(ctx context.Context, table string, pk string, rk *string
 		return fmt.Errorf("failed to marshal data: %w", err)
 	}
 
-	if rk != nil {
-		_, err = s.db.ExecContext(ctx,
-			fmt.Sprintf(
-				`INSERT INTO %q (pk, rk, data, created_at, updated_at)
-				 VALUES ($1, $2, $3, now(), now())
-				 ON CONFLICT (pk, rk) DO UPDATE SET data = $3, updated_at = now()`,
-				table,
-			),
-			pk, *rk, dataBytes,
-		)
-	} else {
-		_, err = s.db.ExecContext(ctx,
-			fmt.Sprintf(
-				`INSERT INTO %q (pk, data, created_at, updated_at)
-				 VALUES ($1, $2, now(), now())
-				 ON CONFLICT (pk) DO UPDATE SET data = $2, updated_at = now()`,
-				table,
-			),
-			pk, dataBytes,
-		)
-	}
-	if err != nil {
+	keyCols, args := itemKeyColumns(ctx, pk, rk)
+	args = append(args, dataBytes)
+
+	query := fmt.Sprintf(
+		`INSERT INTO %s (%s, data, created_at, updated_at)
+		 VALUES (%s, now(), now())
+		 ON CONFLICT (%s) DO UPDATE SET data = EXCLUDED.data, updated_at = now()`,
+		s.table(ctx, table),
+		strings.Join(keyCols, ", "),
+		placeholders(len(args)),
+		strings.Join(keyCols, ", "),
+	)
+	if _, err := s.write(ctx, "PutItem", table, query, args...); err != nil {
 		return fmt.Errorf("failed to put item: %w", err)
 	}
 	return nil
 }
 
+// PutItemIfAbsent creates an item only when no item with the same keys exists.
+// It reports whether the item was created.
+func (s *Store) PutItemIfAbsent(ctx context.Context, table string, pk string, rk *string, data map[string]any) (bool, error) {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	cols, args := itemKeyColumns(ctx, pk, rk)
+	args = append(args, dataBytes)
+
+	query := fmt.Sprintf(
+		`INSERT INTO %s (%s, data, created_at, updated_at)
+		 VALUES (%s, now(), now())
+		 ON CONFLICT DO NOTHING`,
+		s.table(ctx, table), strings.Join(cols, ", "), placeholders(len(args)),
+	)
+	affected, err := s.write(ctx, "PutItemIfAbsent", table, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to create item: %w", err)
+	}
+	return affected > 0, nil
+}
+
 // PutItemIfUnchanged updates an item only when updated_at still matches expectedUpdatedAt.
 func (s *Store) PutItemIfUnchanged(ctx context.Context, table string, pk string, rk *string, data map[string]any, expectedUpdatedAt time.Time) (bool, error) {
 	dataBytes, err := json.Marshal(data)
@@ -186,58 +206,84 @@ func (s *Store) PutItemIfUnchanged(ctx context.Context, table string, pk string,
 		return false, fmt.Errorf("failed to marshal data: %w", err)
 	}
 
-	var res sql.Result
-	if rk != nil {
-		res, err = s.db.ExecContext(ctx,
-			fmt.Sprintf(
-				`UPDATE %q
-				 SET data = $3, updated_at = now()
-				 WHERE pk = $1 AND rk = $2 AND updated_at = $4`,
-				table,
-			),
-			pk, *rk, dataBytes, expectedUpdatedAt,
-		)
-	} else {
-		res, err = s.db.ExecContext(ctx,
-			fmt.Sprintf(
-				`UPDATE %q
-				 SET data = $2, updated_at = now()
-				 WHERE pk = $1 AND updated_at = $3`,
-				table,
-			),
-			pk, dataBytes, expectedUpdatedAt,
-		)
-	}
+	where, args := itemKeyFilter(ctx, pk, rk)
+	args = append(args, dataBytes, expectedUpdatedAt)
+	query := fmt.Sprintf(
+		`UPDATE %s
+		 SET data = $%d, updated_at = now()
+		 WHERE %s AND updated_at = $%d`,
+		s.table(ctx, table), len(args)-1, where, len(args),
+	)
+	affected, err := s.write(ctx, "PutItemIfUnchanged", table, query, args...)
 	if err != nil {
 		return false, fmt.Errorf("failed to conditionally update item: %w", err)
 	}
+	return affected > 0, nil
+}
 
-	affected, err := res.RowsAffected()
+// DeleteItem deletes an item by PK (and optionally RK). It reports whether
+// there was an item to delete.
+func (s *Store) DeleteItem(ctx context.Context, table string, pk string, rk *string) (bool, error) {
+	where, args := itemKeyFilter(ctx, pk, rk)
+	query := fmt.Sprintf(`DELETE FROM %s WHERE %s`, s.table(ctx, table), where)
+	affected, err := s.write(ctx, "DeleteItem", table, query, args...)
 	if err != nil {
-		return false, fmt.Errorf("failed to inspect update result: %w", err)
+		return false, fmt.Errorf("failed to delete item: %w", err)
 	}
+	return affected > 0, nil
+}
 
+// DeleteItemIfUnchanged deletes an item only when updated_at still matches
+// expectedUpdatedAt. It reports whether the item was deleted.
+func (s *Store) DeleteItemIfUnchanged(ctx context.Context, table string, pk string, rk *string, expectedUpdatedAt time.Time) (bool, error) {
+	where, args := itemKeyFilter(ctx, pk, rk)
+	args = append(args, expectedUpdatedAt)
+	query := fmt.Sprintf(`DELETE FROM %s WHERE %s AND updated_at = $%d`, s.table(ctx, table), where, len(args))
+	affected, err := s.write(ctx, "DeleteItemIfUnchanged", table, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to conditionally delete item: %w", err)
+	}
 	return affected > 0, nil
 }
 
-// DeleteItem deletes an item by PK (and optionally RK).
-func (s *Store) DeleteItem(ctx context.Context, table string, pk string, rk *string) error {
-	var err error
+// itemKeyColumns returns the key columns and values identifying an item for
+// an insert, led by the request's tenant when one is set.
+func itemKeyColumns(ctx context.Context, pk string, rk *string) ([]string, []any) {
+	cols := []string{"pk"}
+	args := []any{pk}
 	if rk != nil {
-		_, err = s.db.ExecContext(ctx,
-			fmt.Sprintf(`DELETE FROM %q WHERE pk = $1 AND rk = $2`, table),
-			pk, *rk,
-		)
-	} else {
-		_, err = s.db.ExecContext(ctx,
-			fmt.Sprintf(`DELETE FROM %q WHERE pk = $1`, table),
-			pk,
-		)
+		cols = append(cols, "rk")
+		args = append(args, *rk)
 	}
-	if err != nil {
-		return fmt.Errorf("failed to delete item: %w", err)
+	if tenant, ok := tenantFromContext(ctx); ok {
+		cols = append([]string{"tenant"}, cols...)
+		args = append([]any{tenant}, args...)
 	}
-	return nil
+	return cols, args
+}
+
+// placeholders returns "$1, $2, ..., $n".
+func placeholders(n int) string {
+	p := make([]string, n)
+	for i := range p {
+		p[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return strings.Join(p, ", ")
+}
+
+// itemKeyFilter returns the WHERE clause and arguments that select a single
+// item by its keys, scoped to the request's tenant when one is set.
+func itemKeyFilter(ctx context.Context, pk string, rk *string) (string, []any) {
+	where := []string{"pk = $1"}
+	args := []any{pk}
+	argIdx := 2
+	if rk != nil {
+		where = append(where, "rk = $2")
+		args = append(args, *rk)
+		argIdx++
+	}
+	where, args, _ = appendTenantFilter(ctx, where, args, argIdx)
+	return strings.Join(where, " AND "), args
 }
 
 // ListItems lists items in a partition with pagination and optional RK filtering.
@@ -246,10 +292,11 @@ func (s *Store) ListItems(ctx context.Context, table string, pk string, hasRK bo
 	args := []any{pk}
 	argIdx := 2
 
+	where, args, argIdx = appendTenantFilter(ctx, where, args, argIdx)
 	where, args, argIdx = appendRKFilters(where, args, argIdx, hasRK, opts)
 	where, args, argIdx = appendCursorFilter(where, args, argIdx, hasRK, opts.PageToken, "rk")
 
-	return s.queryItems(ctx, table, where, args, argIdx, opts.Limit, hasRK, "pk", "rk")
+	return s.queryItems(ctx, "ListItems", table, "", where, args, argIdx, opts, hasRK, "pk", "rk")
 }
 
 // ScanTable performs a full table scan with pagination.
@@ -258,9 +305,10 @@ func (s *Store) ScanTable(ctx context.Context, table string, hasRK bool, opts Li
 	var args []any
 	argIdx := 1
 
+	where, args, argIdx = appendTenantFilter(ctx, where, args, argIdx)
 	where, args, argIdx = appendCursorFilter(where, args, argIdx, hasRK, opts.PageToken, "rk")
 
-	return s.queryItems(ctx, table, where, args, argIdx, opts.Limit, hasRK, "pk", "rk")
+	return s.queryItems(ctx, "ScanTable", table, "", where, args, argIdx, opts, hasRK, "pk", "rk")
 }
 
 // QueryIndex queries a GSI by its partition key value.
@@ -271,6 +319,8 @@ func (s *Store) QueryIndex(ctx context.Context, table string, index IndexQueryCo
 	args := []any{indexPk}
 	argIdx := 2
 
+	where, args, argIdx = appendTenantFilter(ctx, where, args, argIdx)
+
 	hasRK := index.RKField != ""
 	rkExpr := ""
 	if hasRK {
@@ -280,7 +330,7 @@ func (s *Store) QueryIndex(ctx context.Context, table string, index IndexQueryCo
 
 	where, args, argIdx = appendCursorFilter(where, args, argIdx, hasRK, opts.PageToken, rkExpr)
 
-	return s.queryItems(ctx, table, where, args, argIdx, opts.Limit, hasRK, pkExpr, rkExpr)
+	return s.queryItems(ctx, "QueryIndex", table, index.Name, where, args, argIdx, opts, hasRK, pkExpr, rkExpr)
 }
 
 // ScanIndex performs a full index scan with pagination.
@@ -292,6 +342,8 @@ func (s *Store) ScanIndex(ctx context.Context, table string, index IndexQueryCon
 	var args []any
 	argIdx := 1
 
+	where, args, argIdx = appendTenantFilter(ctx, where, args, argIdx)
+
 	hasRK := index.RKField != ""
 	rkExpr := ""
 	if hasRK {
@@ -301,7 +353,7 @@ func (s *Store) ScanIndex(ctx context.Context, table string, index IndexQueryCon
 
 	where, args, argIdx = appendCursorFilter(where, args, argIdx, hasRK, opts.PageToken, rkExpr)
 
-	return s.queryItems(ctx, table, where, args, argIdx, opts.Limit, hasRK, pkExpr, rkExpr)
+	return s.queryItems(ctx, "ScanIndex", table, index.Name, where, args, argIdx, opts, hasRK, pkExpr, rkExpr)
 }
 
 // GetItemByIndex retrieves a single item from a GSI by pk+rk.
@@ -309,17 +361,18 @@ func (s *Store) GetItemByIndex(ctx context.Context, table string, index IndexQue
 	pkExpr := fmt.Sprintf("data->>%s", quoteStringLiteral(index.PKField))
 	rkExpr := fmt.Sprintf("data->>%s", quoteStringLiteral(index.RKField))
 
+	where := []string{pkExpr + " = $1", rkExpr + " = $2"}
+	where, args, _ := appendTenantFilter(ctx, where, []any{indexPk, indexRk}, 3)
+
 	query := fmt.Sprintf(
-		`SELECT pk, rk, data FROM %q WHERE %s = $1 AND %s = $2 LIMIT 1`,
-		table, pkExpr, rkExpr,
+		`SELECT pk, rk, data FROM %s WHERE %s LIMIT 1`,
+		s.table(ctx, table), strings.Join(where, " AND "),
 	)
 
-	row := s.db.QueryRowContext(ctx, query, indexPk, indexRk)
-
 	var pk string
 	var rk sql.NullString
 	var dataBytes []byte
-	if err := row.Scan(&pk, &rk, &dataBytes); err != nil {
+	if err := s.queryRow(ctx, "GetItemByIndex", table, index.Name, query, args, &pk, &rk, &dataBytes); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -338,8 +391,10 @@ func (s *Store) GetItemByIndex(ctx context.Context, table string, index IndexQue
 	return &ItemResult{PK: pk, RK: rkVal, Data: data}, nil
 }
 
-// queryItems builds and executes a paginated SELECT query.
-func (s *Store) queryItems(ctx context.Context, table string, where []string, args []any, argIdx int, limit int, hasRK bool, pkExpr string, rkExpr string) (*ListResult, error) {
+// queryItems builds and executes a paginated SELECT query, in a span named
+// after op. With opts.Explain, it returns the query's plan instead of items.
+func (s *Store) queryItems(ctx context.Context, op, table, index string, where []string, args []any, argIdx int, opts ListOptions, hasRK bool, pkExpr string, rkExpr string) (_ *ListResult, err error) {
+	limit := opts.Limit
 	if limit <= 0 {
 		limit = 50
 	}
@@ -350,13 +405,28 @@ func (s *Store) queryItems(ctx context.Context, table string, where []string, ar
 		orderBy = pkExpr + ", " + rkExpr
 	}
 
-	query := fmt.Sprintf(`SELECT pk, rk, data FROM %q`, table)
+	query := fmt.Sprintf(`SELECT pk, rk, data FROM %s`, s.table(ctx, table))
 	if len(where) > 0 {
 		query += " WHERE " + strings.Join(where, " AND ")
 	}
 	query += fmt.Sprintf(` ORDER BY %s LIMIT $%d`, orderBy, argIdx)
 	args = append(args, fetchLimit)
 
+	if opts.Explain {
+		plan, err := s.explain(ctx, op, table, index, query, args)
+		if err != nil {
+			return nil, err
+		}
+		return &ListResult{Plan: plan}, nil
+	}
+
+	defer s.logIfSlow(ctx, op, table, index, query, args, time.Now())
+	ctx, span := startSpan(ctx, op, table, query, indexAttributes(index)...)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query items: %w", err)
@@ -392,6 +462,7 @@ func (s *Store) queryItems(ctx context.Context, table string, where []string, ar
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("row iteration error: %w", err)
 	}
+	span.SetAttributes(attribute.Int("db.rows", len(collected)))
 
 	result := &ListResult{}
 	hasMore := len(collected) > limit
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+type tenantContextKey struct{}
+
+// WithTenant returns a context whose Store operations are scoped to one tenant
+// of a row-level multi-tenant table: writes store the tenant in the hidden
+// tenant column and reads only match rows with that tenant. It must only be
+// set for tables configured with a tenantClaim.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+func tenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenant, ok
+}
+
+// appendTenantFilter restricts a query to the request's tenant, if any.
+func appendTenantFilter(ctx context.Context, where []string, args []any, argIdx int) ([]string, []any, int) {
+	tenant, ok := tenantFromContext(ctx)
+	if !ok {
+		return where, args, argIdx
+	}
+	where = append(where, fmt.Sprintf("tenant = $%d", argIdx))
+	args = append(args, tenant)
+	return where, args, argIdx + 1
+}
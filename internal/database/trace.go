@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan starts a client span for a Store query against table. The
+// statement is recorded as is: values are always bound as parameters, so it
+// carries the shape of the query and no item data.
+func startSpan(ctx context.Context, op, table, statement string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "db "+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(append([]attribute.KeyValue{
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation", op),
+		attribute.String("db.sql.table", table),
+		attribute.String("db.statement", statement),
+	}, attrs...)...))
+}
+
+// indexAttributes returns the span attribute naming the index a query
+// reads, if any.
+func indexAttributes(index string) []attribute.KeyValue {
+	if index == "" {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.String("db.index", index)}
+}
+
+// contextExecer is satisfied by both *sql.DB and *sql.Tx.
+type contextExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// exec runs a statement in its own span and returns the number of rows it
+// affected.
+func (s *Store) exec(ctx context.Context, op, table, query string, args ...any) (int64, error) {
+	return s.execOn(ctx, s.db, op, table, query, args...)
+}
+
+// execOn is exec on db, which may be a transaction.
+func (s *Store) execOn(ctx context.Context, db contextExecer, op, table, query string, args ...any) (_ int64, err error) {
+	defer s.logIfSlow(ctx, op, table, "", query, args, time.Now())
+	ctx, span := startSpan(ctx, op, table, query)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int("db.rows", int(n)))
+	return n, nil
+}
+
+// queryRow runs a single-row query in its own span and scans the row into
+// dest. Like sql.Row.Scan, it returns sql.ErrNoRows when no row matched.
+func (s *Store) queryRow(ctx context.Context, op, table, index, query string, args []any, dest ...any) error {
+	defer s.logIfSlow(ctx, op, table, index, query, args, time.Now())
+	ctx, span := startSpan(ctx, op, table, query, indexAttributes(index)...)
+	defer span.End()
+
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	switch {
+	case err == nil:
+		span.SetAttributes(attribute.Int("db.rows", 1))
+	case errors.Is(err, sql.ErrNoRows):
+		span.SetAttributes(attribute.Int("db.rows", 0))
+	default:
+		tracing.RecordError(span, err)
+	}
+	return err
+}
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/transform"
+)
+
+const metaTransformsRowName = "_transforms"
+
+// appliedTransforms is the JSON structure stored in the _transforms row of _meta.
+type appliedTransforms struct {
+	Applied []appliedTransform `json:"applied"`
+}
+
+type appliedTransform struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Checksum  string    `json:"checksum"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// applyTransforms runs every data transformation not yet recorded in _meta, in
+// version order, and records each one so it runs exactly once.
+func applyTransforms(tx *migrationTx, tables []config.TableConfig, migrations []transform.Migration) error {
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	byName := make(map[string]config.TableConfig, len(tables))
+	for _, t := range tables {
+		byName[t.Name] = t
+	}
+
+	var state appliedTransforms
+	var stateJSON []byte
+	err := tx.QueryRow(`SELECT config FROM _meta WHERE table_name = $1`, metaTransformsRowName).Scan(&stateJSON)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return fmt.Errorf("querying applied transforms: %w", err)
+	default:
+		if err := json.Unmarshal(stateJSON, &state); err != nil {
+			return fmt.Errorf("parsing applied transforms: %w", err)
+		}
+	}
+
+	applied := make(map[int]appliedTransform, len(state.Applied))
+	for _, a := range state.Applied {
+		applied[a.Version] = a
+	}
+
+	changed := false
+	for _, m := range migrations {
+		if a, ok := applied[m.Version]; ok {
+			if a.Name != m.Name || a.Checksum != m.Checksum {
+				return fmt.Errorf("migration %q was modified after it was applied as %q", m.Name, a.Name)
+			}
+			continue
+		}
+
+		t, ok := byName[m.Table]
+		if !ok {
+			return fmt.Errorf("migration %q: table %q is not configured", m.Name, m.Table)
+		}
+
+		for i, step := range m.Steps {
+			stmts := transformStatements(t, step)
+			tx.record(PlanAction{
+				Action:      ActionApplyTransform,
+				Table:       t.Name,
+				Description: fmt.Sprintf("apply migration %q step %d to table %q: %s", m.Name, i+1, t.Name, step),
+				Destructive: step.Op() == "delete",
+			})
+			if query := transformConflictQuery(t, step); query != "" {
+				var conflicts int
+				if err := tx.QueryRow(query).Scan(&conflicts); err != nil {
+					return fmt.Errorf("migration %q: step %d (%s): checking the destination: %w", m.Name, i+1, step, err)
+				}
+				if conflicts > 0 {
+					return fmt.Errorf("migration %q: step %d (%s): %d item(s) already have a value at the destination", m.Name, i+1, step, conflicts)
+				}
+			}
+			if tx.dryRun {
+				continue
+			}
+
+			var updated int64
+			for _, stmt := range stmts {
+				res, err := tx.Exec(stmt)
+				if err != nil {
+					return fmt.Errorf("migration %q: step %d (%s): %w", m.Name, i+1, step, err)
+				}
+				updated, err = res.RowsAffected()
+				if err != nil {
+					return fmt.Errorf("migration %q: step %d (%s): %w", m.Name, i+1, step, err)
+				}
+			}
+			slog.Info("applied migration step", "migration", m.Name, "step", i+1, "table", t.Name, "action", step, "updated", updated)
+		}
+
+		state.Applied = append(state.Applied, appliedTransform{
+			Version:   m.Version,
+			Name:      m.Name,
+			Checksum:  m.Checksum,
+			AppliedAt: time.Now().UTC(),
+		})
+		changed = true
+	}
+
+	if !changed || tx.dryRun {
+		return nil
+	}
+
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal applied transforms: %w", err)
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO _meta (table_name, config)
+		 VALUES ($1, $2)
+		 ON CONFLICT (table_name)
+		 DO UPDATE SET config = EXCLUDED.config, updated_at = now()`,
+		metaTransformsRowName,
+		payload,
+	); err != nil {
+		return fmt.Errorf("recording applied transforms: %w", err)
+	}
+	return nil
+}
+
+// transformStatements builds the UPDATE statements for a single step. The last
+// statement performs the transformation; any earlier statements create missing
+// parent objects for nested destination paths. Items whose destination parent
+// exists but is not an object are left unchanged.
+func transformStatements(t config.TableConfig, step transform.Step) []string {
+	switch step.Op() {
+	case "rename":
+		return moveStatements(t, step.Rename.From, step.Rename.To)
+	case "move":
+		return moveStatements(t, step.Move.From, step.Move.To)
+	case "copy":
+		source := copySourceExpr(t, step.Copy.From)
+		cond := source + " IS NOT NULL"
+		return setStatements(t, step.Copy.To, cond, fmt.Sprintf("jsonb_set(data, %s, %s, true)", pathLiteral(step.Copy.To), source))
+	case "setDefault":
+		value, _ := json.Marshal(step.SetDefault.Value)
+		cond := jsonPathExpr(step.SetDefault.Field) + " IS NULL"
+		return setStatements(t, step.SetDefault.Field, cond, fmt.Sprintf("jsonb_set(data, %s, %s::jsonb, true)", pathLiteral(step.SetDefault.Field), quoteStringLiteral(string(value))))
+	case "delete":
+		return []string{fmt.Sprintf(
+			`UPDATE %q SET data = data #- %s, updated_at = now() WHERE %s IS NOT NULL`,
+			t.Name, pathLiteral(step.Delete.Field), jsonPathExpr(step.Delete.Field),
+		)}
+	}
+	return nil
+}
+
+// transformConflictQuery returns a query counting the items whose value at a
+// rename, move or copy destination would be overwritten, or "" for other
+// steps.
+func transformConflictQuery(t config.TableConfig, step transform.Step) string {
+	var source, to string
+	switch step.Op() {
+	case "rename":
+		source, to = jsonPathExpr(step.Rename.From), step.Rename.To
+	case "move":
+		source, to = jsonPathExpr(step.Move.From), step.Move.To
+	case "copy":
+		source, to = copySourceExpr(t, step.Copy.From), step.Copy.To
+	default:
+		return ""
+	}
+	return fmt.Sprintf(
+		`SELECT count(*) FROM %q WHERE %s IS NOT NULL AND %s IS NOT NULL`,
+		t.Name, source, jsonPathExpr(to),
+	)
+}
+
+// copySourceExpr returns the SQL expression for a copy source: the key column
+// for the table's key fields, otherwise the stored attribute.
+func copySourceExpr(t config.TableConfig, from string) string {
+	switch {
+	case from == t.PrimaryKey.Field:
+		return "to_jsonb(pk)"
+	case t.RangeKey != nil && from == t.RangeKey.Field:
+		return "to_jsonb(rk)"
+	}
+	return jsonPathExpr(from)
+}
+
+func moveStatements(t config.TableConfig, from, to string) []string {
+	cond := jsonPathExpr(from) + " IS NOT NULL"
+	expr := fmt.Sprintf("jsonb_set(data #- %s, %s, %s, true)", pathLiteral(from), pathLiteral(to), jsonPathExpr(from))
+	return setStatements(t, to, cond, expr)
+}
+
+// setStatements creates missing parents of path on matching items, then applies expr.
+func setStatements(t config.TableConfig, path, cond, expr string) []string {
+	segments := transform.SplitPath(path)
+	var stmts []string
+	for i := 1; i < len(segments); i++ {
+		parent := strings.Join(segments[:i], ".")
+		where := fmt.Sprintf("%s AND %s IS NULL", cond, jsonPathExpr(parent))
+		if i > 1 {
+			where += fmt.Sprintf(" AND jsonb_typeof(%s) = 'object'", jsonPathExpr(strings.Join(segments[:i-1], ".")))
+		}
+		stmts = append(stmts, fmt.Sprintf(
+			`UPDATE %q SET data = jsonb_set(data, %s, '{}'::jsonb, true) WHERE %s`,
+			t.Name, pathLiteral(parent), where,
+		))
+	}
+
+	where := cond
+	if len(segments) > 1 {
+		parent := strings.Join(segments[:len(segments)-1], ".")
+		where += fmt.Sprintf(" AND jsonb_typeof(%s) = 'object'", jsonPathExpr(parent))
+	}
+	stmts = append(stmts, fmt.Sprintf(
+		`UPDATE %q SET data = %s, updated_at = now() WHERE %s`,
+		t.Name, expr, where,
+	))
+	return stmts
+}
+
+// pathLiteral renders a dot-separated attribute path as a PostgreSQL text array literal.
+func pathLiteral(path string) string {
+	return quoteStringLiteral("{"+strings.Join(transform.SplitPath(path), ",")+"}") + "::text[]"
+}
+
+func jsonPathExpr(path string) string {
+	return "data #> " + pathLiteral(path)
+}
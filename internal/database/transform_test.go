@@ -0,0 +1,108 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/transform"
+)
+
+func TestTransformStatements(t *testing.T) {
+	table := config.TableConfig{
+		Name:       "users",
+		PrimaryKey: config.KeyConfig{Field: "userId"},
+	}
+
+	tests := []struct {
+		name string
+		step transform.Step
+		want []string
+	}{
+		{
+			name: "rename",
+			step: transform.Step{Rename: &transform.FieldPair{From: "fullName", To: "name"}},
+			want: []string{
+				`UPDATE "users" SET data = jsonb_set(data #- '{fullName}'::text[], '{name}'::text[], data #> '{fullName}'::text[], true), updated_at = now() WHERE data #> '{fullName}'::text[] IS NOT NULL`,
+			},
+		},
+		{
+			name: "move into nested object",
+			step: transform.Step{Move: &transform.FieldPair{From: "city", To: "address.city"}},
+			want: []string{
+				`UPDATE "users" SET data = jsonb_set(data, '{address}'::text[], '{}'::jsonb, true) WHERE data #> '{city}'::text[] IS NOT NULL AND data #> '{address}'::text[] IS NULL`,
+				`UPDATE "users" SET data = jsonb_set(data #- '{city}'::text[], '{address,city}'::text[], data #> '{city}'::text[], true), updated_at = now() WHERE data #> '{city}'::text[] IS NOT NULL AND jsonb_typeof(data #> '{address}'::text[]) = 'object'`,
+			},
+		},
+		{
+			name: "copy key field",
+			step: transform.Step{Copy: &transform.FieldPair{From: "userId", To: "ownerId"}},
+			want: []string{
+				`UPDATE "users" SET data = jsonb_set(data, '{ownerId}'::text[], to_jsonb(pk), true), updated_at = now() WHERE to_jsonb(pk) IS NOT NULL`,
+			},
+		},
+		{
+			name: "set default",
+			step: transform.Step{SetDefault: &transform.DefaultValue{Field: "status", Value: "it's active"}},
+			want: []string{
+				`UPDATE "users" SET data = jsonb_set(data, '{status}'::text[], '"it''s active"'::jsonb, true), updated_at = now() WHERE data #> '{status}'::text[] IS NULL`,
+			},
+		},
+		{
+			name: "delete",
+			step: transform.Step{Delete: &transform.Field{Field: "profile.legacy"}},
+			want: []string{
+				`UPDATE "users" SET data = data #- '{profile,legacy}'::text[], updated_at = now() WHERE data #> '{profile,legacy}'::text[] IS NOT NULL`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := transformStatements(table, tt.step)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d statements, got %d: %v", len(tt.want), len(got), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("statement %d mismatch:\n got: %s\nwant: %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTransformConflictQuery(t *testing.T) {
+	table := config.TableConfig{
+		Name:       "users",
+		PrimaryKey: config.KeyConfig{Field: "userId"},
+	}
+
+	tests := []struct {
+		name string
+		step transform.Step
+		want string
+	}{
+		{
+			name: "move",
+			step: transform.Step{Move: &transform.FieldPair{From: "city", To: "address.city"}},
+			want: `SELECT count(*) FROM "users" WHERE data #> '{city}'::text[] IS NOT NULL AND data #> '{address,city}'::text[] IS NOT NULL`,
+		},
+		{
+			name: "copy key field",
+			step: transform.Step{Copy: &transform.FieldPair{From: "userId", To: "ownerId"}},
+			want: `SELECT count(*) FROM "users" WHERE to_jsonb(pk) IS NOT NULL AND data #> '{ownerId}'::text[] IS NOT NULL`,
+		},
+		{
+			name: "delete",
+			step: transform.Step{Delete: &transform.Field{Field: "legacyFlag"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformConflictQuery(table, tt.step); got != tt.want {
+				t.Fatalf("mismatch:\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/middleware"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAccessRuleRejectsMissingScope(t *testing.T) {
+	h, err := New(nil, []config.TableConfig{
+		{
+			Name: "items",
+			PrimaryKey: config.KeyConfig{
+				Field:   "itemId",
+				Pattern: "^[a-z]+$",
+			},
+			Access: &config.AccessConfig{
+				Delete: &config.AccessRule{Roles: []string{"admin"}},
+			},
+			Schema: map[string]any{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]any{
+					"itemId": map[string]any{"type": "string"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	h.SetupRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/items/data/abc/_item", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.ClaimsKey, jwt.MapClaims{"roles": []any{"reader"}}))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "requires one of roles admin") {
+		t.Fatalf("expected reason in response, got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/database"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/logging"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/middleware"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/validate"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const typeAuditEntries = "auditEntries"
+
+// auditResponse is the JSON envelope for the audit query endpoint.
+type auditResponse struct {
+	Type    string                `json:"_type"`
+	Entries []database.AuditEntry `json:"entries"`
+	Meta    listMeta              `json:"_meta"`
+}
+
+// auditDiff reports whether the table records item data before and after
+// each write, which needs the stored item read first.
+func auditDiff(th *tableHandler) bool {
+	return th.config.Audit != nil && th.config.Audit.Diff
+}
+
+// audit prepares the audit entry for a write on an audited table. With the
+// table sink, the returned context makes the Store insert the entry in the
+// write's transaction, so the write fails when it cannot be recorded. With
+// the log sink, done logs the entry and must be called once the write
+// changed the item. before and after are only kept when the table records
+// diffs.
+func audit(r *http.Request, th *tableHandler, op, pk string, rk *string, before, after map[string]any) (ctx context.Context, done func()) {
+	cfg := th.config.Audit
+	if cfg == nil {
+		return r.Context(), func() {}
+	}
+
+	claims, _ := r.Context().Value(middleware.ClaimsKey).(jwt.MapClaims)
+	subject, _ := claims["sub"].(string)
+	entry := database.AuditEntry{
+		RequestID: logging.RequestID(r.Context()),
+		Subject:   subject,
+		Operation: op,
+		Table:     th.config.Name,
+		PK:        pk,
+		RK:        rk,
+	}
+	if cfg.Diff {
+		entry.Before = before
+		entry.After = after
+	}
+
+	if cfg.Sink == "log" {
+		return r.Context(), func() { logAuditEntry(r.Context(), entry) }
+	}
+	return database.WithAudit(r.Context(), entry), func() {}
+}
+
+// logAuditEntry logs entry as JSON, embedded as is by the json log format.
+func logAuditEntry(ctx context.Context, entry database.AuditEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to marshal audit entry", "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "audit", "entry", json.RawMessage(b))
+}
+
+// handleAuditQuery handles GET /_admin/audit, filtered by the table, pk,
+// rk, and subject query parameters.
+func (h *Handler) handleAuditQuery() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		opts := h.parseListOptions(r)
+		query := database.AuditQuery{
+			Table:     q.Get("table"),
+			PK:        q.Get("pk"),
+			Subject:   q.Get("subject"),
+			Limit:     opts.Limit,
+			PageToken: opts.PageToken,
+		}
+
+		if query.Table != "" {
+			if _, ok := h.tables[query.Table]; !ok {
+				writeError(w, http.StatusBadRequest, "unknown table "+query.Table)
+				return
+			}
+		}
+		if query.PK != "" {
+			if query.Table == "" {
+				writeError(w, http.StatusBadRequest, "pk requires table")
+				return
+			}
+			if err := validate.ValidateKeyValue(query.PK); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		if q.Has("rk") {
+			if query.PK == "" {
+				writeError(w, http.StatusBadRequest, "rk requires pk")
+				return
+			}
+			rk := q.Get("rk")
+			if err := validate.ValidateKeyValue(rk); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			query.RK = &rk
+		}
+
+		result, err := h.store.QueryAudit(r.Context(), query)
+		if err != nil {
+			writeInternalError(w, r, "failed to query audit log", err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, auditResponse{
+			Type:    typeAuditEntries,
+			Entries: result.Entries,
+			Meta:    listMeta{NextPageToken: result.NextPageToken},
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newAdminMux(t *testing.T, adminAccess *config.AccessRule) *http.ServeMux {
+	t.Helper()
+	h, err := NewWithOptions(nil, []config.TableConfig{
+		{
+			Name: "items",
+			PrimaryKey: config.KeyConfig{
+				Field:   "itemId",
+				Pattern: "^[a-z]+$",
+			},
+			Audit: &config.AuditConfig{Sink: "table"},
+			Schema: map[string]any{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]any{
+					"itemId": map[string]any{"type": "string"},
+				},
+			},
+		},
+	}, Options{AdminAccess: adminAccess})
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	h.SetupRoutes(mux)
+	return mux
+}
+
+func TestAuditQueryRequiresAdminAccess(t *testing.T) {
+	rule := &config.AccessRule{Scopes: []string{"admin"}}
+
+	tests := []struct {
+		name     string
+		rule     *config.AccessRule
+		url      string
+		claims   jwt.MapClaims
+		wantCode int
+		wantBody string
+	}{
+		{name: "admin disabled", url: "/_admin/audit", claims: jwt.MapClaims{"scope": "admin"}, wantCode: http.StatusNotFound},
+		{name: "missing scope", rule: rule, url: "/_admin/audit", claims: jwt.MapClaims{"scope": "items"}, wantCode: http.StatusForbidden, wantBody: "forbidden"},
+		{name: "unknown table", rule: rule, url: "/_admin/audit?table=orders", claims: jwt.MapClaims{"scope": "admin"}, wantCode: http.StatusBadRequest, wantBody: "unknown table orders"},
+		{name: "pk without table", rule: rule, url: "/_admin/audit?pk=abc", claims: jwt.MapClaims{"scope": "admin"}, wantCode: http.StatusBadRequest, wantBody: "pk requires table"},
+		{name: "rk without pk", rule: rule, url: "/_admin/audit?table=items&rk=abc", claims: jwt.MapClaims{"scope": "admin"}, wantCode: http.StatusBadRequest, wantBody: "rk requires pk"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newAdminMux(t, tt.rule)
+			rec := serveWithClaims(mux, httptest.NewRequest(http.MethodGet, tt.url, nil), tt.claims)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Fatalf("expected body containing %q, got %s", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
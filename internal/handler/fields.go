@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/access"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/middleware"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// maskFields removes the attributes the caller may not read. data is not
+// modified.
+func maskFields(r *http.Request, th *tableHandler, data map[string]any) map[string]any {
+	if th.config.Access == nil || len(th.config.Access.Fields) == 0 {
+		return data
+	}
+
+	claims, _ := r.Context().Value(middleware.ClaimsKey).(jwt.MapClaims)
+	var masked map[string]any
+	for field, fa := range th.config.Access.Fields {
+		if fa.Read == nil {
+			continue
+		}
+		if _, ok := data[field]; !ok {
+			continue
+		}
+		if access.Check(fa.Read, claims, th.rolesClaim) == nil {
+			continue
+		}
+		if masked == nil {
+			masked = maps.Clone(data)
+		}
+		delete(masked, field)
+	}
+	if masked == nil {
+		return data
+	}
+	return masked
+}
+
+// withIndexKeyRead rejects requests on an index's routes unless the caller
+// may read the index's key fields. Querying an index by a value the caller
+// cannot read would otherwise confirm guesses at the masked value.
+func withIndexKeyRead(th *tableHandler, idx config.IndexConfig, next http.HandlerFunc) http.HandlerFunc {
+	if th.config.Access == nil || len(th.config.Access.Fields) == 0 {
+		return next
+	}
+	keys := []string{idx.PrimaryKey.Field}
+	if idx.RangeKey != nil {
+		keys = append(keys, idx.RangeKey.Field)
+	}
+	var rules []*config.AccessRule
+	for _, field := range keys {
+		if fa := th.config.Access.Fields[field]; fa != nil && fa.Read != nil {
+			rules = append(rules, fa.Read)
+		}
+	}
+	if len(rules) == 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := r.Context().Value(middleware.ClaimsKey).(jwt.MapClaims)
+		for _, rule := range rules {
+			if err := access.Check(rule, claims, th.rolesClaim); err != nil {
+				writeError(w, http.StatusForbidden, "forbidden: "+err.Error())
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// lockedFields returns the attributes the caller may not write, sorted.
+func lockedFields(r *http.Request, th *tableHandler) []string {
+	if th.config.Access == nil || len(th.config.Access.Fields) == 0 {
+		return nil
+	}
+
+	claims, _ := r.Context().Value(middleware.ClaimsKey).(jwt.MapClaims)
+	var locked []string
+	for field, fa := range th.config.Access.Fields {
+		if fa.Write != nil && access.Check(fa.Write, claims, th.rolesClaim) != nil {
+			locked = append(locked, field)
+		}
+	}
+	slices.Sort(locked)
+	return locked
+}
+
+// changedLockedField returns the first locked field in body the caller may
+// not send, or "" when there is none. A locked field is allowed only when the
+// caller may read it and its value equals the stored one, so the check cannot
+// confirm guesses at a masked value. A nil stored item has no values, so any
+// locked field in body is a change, except that a merge patch removing an
+// absent field changes nothing.
+func changedLockedField(r *http.Request, th *tableHandler, locked []string, body, stored map[string]any, mergePatch bool) string {
+	claims, _ := r.Context().Value(middleware.ClaimsKey).(jwt.MapClaims)
+	for _, field := range locked {
+		v, ok := body[field]
+		if !ok {
+			continue
+		}
+		if fa := th.config.Access.Fields[field]; fa.Read != nil && access.Check(fa.Read, claims, th.rolesClaim) != nil {
+			return field
+		}
+		old, exists := stored[field]
+		if !exists && mergePatch && v == nil {
+			continue
+		}
+		if !exists || !reflect.DeepEqual(v, old) {
+			return field
+		}
+	}
+	return ""
+}
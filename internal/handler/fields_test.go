@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/middleware"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func fieldAccessTable() *tableHandler {
+	return &tableHandler{
+		config: config.TableConfig{
+			Name:       "users",
+			PrimaryKey: config.KeyConfig{Field: "userId"},
+			Access: &config.AccessConfig{
+				Fields: map[string]*config.FieldAccess{
+					"ssn":   {Read: &config.AccessRule{Scopes: []string{"pii:read"}}, Write: &config.AccessRule{Scopes: []string{"pii:write"}}},
+					"email": {Read: &config.AccessRule{Scopes: []string{"pii:read"}}},
+					"tier":  {Write: &config.AccessRule{Roles: []string{"admin"}}},
+				},
+			},
+		},
+	}
+}
+
+func requestWithClaims(claims jwt.MapClaims) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	return req.WithContext(context.WithValue(req.Context(), middleware.ClaimsKey, claims))
+}
+
+func TestApplyProjection_MasksUnreadableFields(t *testing.T) {
+	th := fieldAccessTable()
+	data := map[string]any{"userId": "u1", "name": "Ann", "ssn": "123", "email": "a@example.com", "tier": "gold"}
+
+	got := applyProjection(requestWithClaims(jwt.MapClaims{"scope": "users"}), data, th)
+	for _, field := range []string{"ssn", "email"} {
+		if _, ok := got[field]; ok {
+			t.Fatalf("expected %s to be masked, got %v", field, got)
+		}
+	}
+	if got["name"] != "Ann" || got["tier"] != "gold" {
+		t.Fatalf("expected readable fields to remain, got %v", got)
+	}
+	if _, ok := data["ssn"]; !ok {
+		t.Fatal("masking must not modify the stored item")
+	}
+
+	got = applyProjection(requestWithClaims(jwt.MapClaims{"scope": "users pii:read"}), data, th)
+	if got["ssn"] != "123" || got["email"] != "a@example.com" {
+		t.Fatalf("expected pii:read to see all fields, got %v", got)
+	}
+}
+
+func TestLockedFields(t *testing.T) {
+	th := fieldAccessTable()
+
+	locked := lockedFields(requestWithClaims(jwt.MapClaims{"scope": "pii:write"}), th)
+	if len(locked) != 1 || locked[0] != "tier" {
+		t.Fatalf("expected only tier to be locked, got %v", locked)
+	}
+
+	locked = lockedFields(requestWithClaims(jwt.MapClaims{"roles": []any{"admin"}}), th)
+	if len(locked) != 1 || locked[0] != "ssn" {
+		t.Fatalf("expected only ssn to be locked, got %v", locked)
+	}
+}
+
+func TestChangedLockedField(t *testing.T) {
+	th := fieldAccessTable()
+	locked := []string{"ssn", "tier"}
+	stored := map[string]any{"ssn": "123", "tier": "gold"}
+	reader := requestWithClaims(jwt.MapClaims{"scope": "pii:read"})
+
+	tests := []struct {
+		name       string
+		r          *http.Request
+		body       map[string]any
+		stored     map[string]any
+		mergePatch bool
+		want       string
+	}{
+		{name: "absent", r: reader, body: map[string]any{"name": "Ann"}, stored: stored},
+		{name: "unchanged", r: reader, body: map[string]any{"ssn": "123", "tier": "gold"}, stored: stored},
+		{name: "changed", r: reader, body: map[string]any{"tier": "platinum"}, stored: stored, want: "tier"},
+		{name: "removed by patch", r: reader, body: map[string]any{"ssn": nil}, stored: stored, mergePatch: true, want: "ssn"},
+		{name: "new item", r: reader, body: map[string]any{"ssn": "123"}, want: "ssn"},
+		{name: "unreadable unchanged", r: requestWithClaims(jwt.MapClaims{}), body: map[string]any{"ssn": "123"}, stored: stored, want: "ssn"},
+		{name: "patch removes absent field", r: reader, body: map[string]any{"tier": nil}, stored: map[string]any{}, mergePatch: true},
+		{name: "put sets absent field to null", r: reader, body: map[string]any{"tier": nil}, stored: map[string]any{}, want: "tier"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := changedLockedField(tt.r, th, locked, tt.body, tt.stored, tt.mergePatch); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithIndexKeyRead(t *testing.T) {
+	th := fieldAccessTable()
+	next := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	byEmail := withIndexKeyRead(th, config.IndexConfig{Name: "by_email", PrimaryKey: config.KeyConfig{Field: "email"}}, next)
+	rec := httptest.NewRecorder()
+	byEmail(rec, requestWithClaims(jwt.MapClaims{"scope": "users"}))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without read access to the index key, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	byEmail(rec, requestWithClaims(jwt.MapClaims{"scope": "pii:read"}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with read access to the index key, got %d", rec.Code)
+	}
+
+	// A write rule alone does not hide the key.
+	byTier := withIndexKeyRead(th, config.IndexConfig{Name: "by_tier", PrimaryKey: config.KeyConfig{Field: "tier"}}, next)
+	rec = httptest.NewRecorder()
+	byTier(rec, requestWithClaims(jwt.MapClaims{"scope": "users"}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for an index on a readable key, got %d", rec.Code)
+	}
+}
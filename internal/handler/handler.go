@@ -4,10 +4,16 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/access"
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/database"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/middleware"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/ratelimit"
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/schema"
 	swaggerdoc "github.com/UnitVectorY-Labs/itemservicecentral/internal/swagger"
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Handler is the top-level HTTP handler that dispatches to per-table handlers.
@@ -15,6 +21,12 @@ type Handler struct {
 	store      *database.Store
 	tables     map[string]*tableHandler
 	openAPIDoc *swaggerdoc.Provider
+	rolesClaim string
+
+	adminAccess *config.AccessRule // guards /_admin endpoints; nil disables them
+	explain     bool               // serve explain=true on list endpoints to admins
+	maxPageSize int                // upper bound for the limit query parameter
+	scanSlots   chan struct{}      // one per in-flight scan; nil when unlimited
 }
 
 // tableHandler holds the configuration and compiled schema for a single table.
@@ -22,12 +34,25 @@ type tableHandler struct {
 	config    config.TableConfig
 	validator *schema.Validator
 	indexes   map[string]config.IndexConfig
+
+	rolesClaim string             // roles claim for field access rules
+	limiter    *ratelimit.Limiter // per-client limit on this table; nil when unlimited
 }
 
 // Options controls optional HTTP handler features.
 type Options struct {
 	SwaggerEnabled bool
 	JWTEnabled     bool
+	RolesClaim     string             // claim holding the caller's roles for access rules; defaults to "roles"
+	AdminAccess    *config.AccessRule // enables the /_admin endpoints for callers satisfying the rule
+	Explain        bool               // lets callers satisfying AdminAccess request query plans with explain=true
+
+	MaxPageSize        int // upper bound for the limit query parameter; defaults to config.DefaultMaxPageSize
+	MaxConcurrentScans int // caps in-flight table and index scans; zero is unlimited
+
+	// Limits carries rate limiter and scan slot state over from the Handler
+	// this one replaces; nil starts afresh.
+	Limits *Limits
 }
 
 // New creates a Handler by compiling schemas and building index lookup maps.
@@ -38,8 +63,16 @@ func New(store *database.Store, tables []config.TableConfig) (*Handler, error) {
 // NewWithOptions creates a Handler with optional features enabled.
 func NewWithOptions(store *database.Store, tables []config.TableConfig, options Options) (*Handler, error) {
 	h := &Handler{
-		store:  store,
-		tables: make(map[string]*tableHandler, len(tables)),
+		store:      store,
+		tables:     make(map[string]*tableHandler, len(tables)),
+		rolesClaim: options.RolesClaim,
+
+		adminAccess: options.AdminAccess,
+		explain:     options.Explain && options.AdminAccess != nil,
+		maxPageSize: options.MaxPageSize,
+	}
+	if h.maxPageSize <= 0 {
+		h.maxPageSize = config.DefaultMaxPageSize
 	}
 
 	for _, t := range tables {
@@ -57,9 +90,22 @@ func NewWithOptions(store *database.Store, tables []config.TableConfig, options
 			config:    t,
 			validator: v,
 			indexes:   idxMap,
+
+			rolesClaim: options.RolesClaim,
 		}
 	}
 
+	// Limits are only taken once the tables compiled, so a reload that fails
+	// leaves them untouched.
+	limits := options.Limits
+	if limits == nil {
+		limits = NewLimits()
+	}
+	h.scanSlots = limits.scanSlots(options.MaxConcurrentScans)
+	for name, l := range limits.limiters(tables) {
+		h.tables[name].limiter = l
+	}
+
 	if options.SwaggerEnabled {
 		h.openAPIDoc = swaggerdoc.NewProvider(tables, options.JWTEnabled)
 	}
@@ -72,6 +118,10 @@ func (h *Handler) SetupRoutes(mux *http.ServeMux) {
 	for name, th := range h.tables {
 		h.registerTableRoutes(mux, name, th)
 	}
+
+	if h.adminAccess != nil {
+		mux.HandleFunc("GET /_admin/audit", h.withAdminAccess(h.handleAuditQuery()))
+	}
 }
 
 func (h *Handler) registerTableRoutes(mux *http.ServeMux, name string, th *tableHandler) {
@@ -80,41 +130,181 @@ func (h *Handler) registerTableRoutes(mux *http.ServeMux, name string, th *table
 		mux.HandleFunc("GET /v1/"+name+"/_openapi", h.handleOpenAPI(name))
 	}
 
+	// Table routes label the request's span, count against the table's rate
+	// limit, check the table's access rules, then are scoped to the caller's
+	// tenant on row-level multi-tenant tables.
+	guard := func(op, index string, fn http.HandlerFunc) http.HandlerFunc {
+		return withSpanAttributes(name, op, index, withRateLimit(th, h.withAccess(th, op, index, withTenant(th, fn))))
+	}
+	handle := func(pattern, op, index string, fn http.HandlerFunc) {
+		mux.HandleFunc(pattern, guard(op, index, fn))
+	}
+
 	hasRK := th.config.RangeKey != nil
 
 	if hasRK {
-		mux.HandleFunc("GET /v1/"+name+"/data/{pk}/{rk}/_item", h.handleGetItem(th))
-		mux.HandleFunc("PUT /v1/"+name+"/data/{pk}/{rk}/_item", h.handlePutItem(th))
-		mux.HandleFunc("PATCH /v1/"+name+"/data/{pk}/{rk}/_item", h.handlePatchItem(th))
-		mux.HandleFunc("DELETE /v1/"+name+"/data/{pk}/{rk}/_item", h.handleDeleteItem(th))
+		handle("GET /v1/"+name+"/data/{pk}/{rk}/_item", access.OpGet, "", h.handleGetItem(th))
+		handle("PUT /v1/"+name+"/data/{pk}/{rk}/_item", access.OpPut, "", h.handlePutItem(th))
+		handle("PATCH /v1/"+name+"/data/{pk}/{rk}/_item", access.OpPatch, "", h.handlePatchItem(th))
+		handle("DELETE /v1/"+name+"/data/{pk}/{rk}/_item", access.OpDelete, "", h.handleDeleteItem(th))
 	} else {
-		mux.HandleFunc("GET /v1/"+name+"/data/{pk}/_item", h.handleGetItem(th))
-		mux.HandleFunc("PUT /v1/"+name+"/data/{pk}/_item", h.handlePutItem(th))
-		mux.HandleFunc("PATCH /v1/"+name+"/data/{pk}/_item", h.handlePatchItem(th))
-		mux.HandleFunc("DELETE /v1/"+name+"/data/{pk}/_item", h.handleDeleteItem(th))
+		handle("GET /v1/"+name+"/data/{pk}/_item", access.OpGet, "", h.handleGetItem(th))
+		handle("PUT /v1/"+name+"/data/{pk}/_item", access.OpPut, "", h.handlePutItem(th))
+		handle("PATCH /v1/"+name+"/data/{pk}/_item", access.OpPatch, "", h.handlePatchItem(th))
+		handle("DELETE /v1/"+name+"/data/{pk}/_item", access.OpDelete, "", h.handleDeleteItem(th))
 	}
 
 	// List items within a partition
-	mux.HandleFunc("GET /v1/"+name+"/data/{pk}/_items", h.handleListItems(th))
+	handle("GET /v1/"+name+"/data/{pk}/_items", access.OpList, "", h.withExplain(h.handleListItems(th)))
 
-	// Table scan
+	// Table scan, and ownedBy=me listing through the owner index
+	var scan, owned http.HandlerFunc
 	if th.config.AllowTableScan {
-		mux.HandleFunc("GET /v1/"+name+"/_items", h.handleScanTable(th))
+		scan = guard(access.OpScan, "", h.withExplain(h.withScanSlot(h.handleScanTable(th))))
+	}
+	if idx, ok := ownerIndex(th); ok {
+		// The listing queries the owner index, so it needs that index's
+		// access rules as well as the list rule.
+		owned = guard(access.OpList, "", h.withAccess(th, access.OpIndex, idx.Name, withIndexKeyRead(th, idx, h.withExplain(h.handleOwnedItems(th, idx)))))
+	}
+	if scan != nil || owned != nil {
+		mux.HandleFunc("GET /v1/"+name+"/_items", handleTableItems(scan, owned))
 	}
 
 	// Index routes
 	for _, idx := range th.config.Indexes {
+		// Index routes also need read access to the index's key fields.
+		handleIndex := func(pattern string, fn http.HandlerFunc) {
+			mux.HandleFunc(pattern, guard(access.OpIndex, idx.Name, withIndexKeyRead(th, idx, fn)))
+		}
+
 		// Query index by pk
-		mux.HandleFunc("GET /v1/"+name+"/_index/"+idx.Name+"/{indexPk}/_items", h.handleQueryIndex(th, idx))
+		handleIndex("GET /v1/"+name+"/_index/"+idx.Name+"/{indexPk}/_items", h.withExplain(h.handleQueryIndex(th, idx)))
 
 		// Index scan
 		if idx.AllowIndexScan {
-			mux.HandleFunc("GET /v1/"+name+"/_index/"+idx.Name+"/_items", h.handleScanIndex(th, idx))
+			handleIndex("GET /v1/"+name+"/_index/"+idx.Name+"/_items", h.withExplain(h.withScanSlot(h.handleScanIndex(th, idx))))
 		}
 
 		// Get single item by index pk+rk
 		if idx.RangeKey != nil {
-			mux.HandleFunc("GET /v1/"+name+"/_index/"+idx.Name+"/{indexPk}/{indexRk}/_item", h.handleGetIndexItem(th, idx))
+			handleIndex("GET /v1/"+name+"/_index/"+idx.Name+"/{indexPk}/{indexRk}/_item", h.handleGetIndexItem(th, idx))
+		}
+	}
+}
+
+// withAccess rejects requests whose token does not satisfy the table's access
+// rule for the operation.
+func (h *Handler) withAccess(th *tableHandler, op, index string, next http.HandlerFunc) http.HandlerFunc {
+	rule := access.RuleFor(th.config.Access, op, index)
+	if rule == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := r.Context().Value(middleware.ClaimsKey).(jwt.MapClaims)
+		if err := access.Check(rule, claims, h.rolesClaim); err != nil {
+			writeError(w, http.StatusForbidden, "forbidden: "+err.Error())
+			return
+		}
+		next(w, r)
+	}
+}
+
+// withAdminAccess rejects requests whose claims do not satisfy the
+// server.admin access rule.
+func (h *Handler) withAdminAccess(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := r.Context().Value(middleware.ClaimsKey).(jwt.MapClaims)
+		if err := access.Check(h.adminAccess, claims, h.rolesClaim); err != nil {
+			writeError(w, http.StatusForbidden, "forbidden: "+err.Error())
+			return
+		}
+		next(w, r)
+	}
+}
+
+// withExplain refuses explain=true unless explain is enabled and the
+// caller satisfies the server.admin access rule.
+func (h *Handler) withExplain(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !explainRequested(r) {
+			next(w, r)
+			return
+		}
+		if !h.explain {
+			writeError(w, http.StatusForbidden, "forbidden: explain is not enabled")
+			return
+		}
+		h.withAdminAccess(next)(w, r)
+	}
+}
+
+// withSpanAttributes adds the table, operation, and index to the request's
+// trace span.
+func withSpanAttributes(table, op, index string, next http.HandlerFunc) http.HandlerFunc {
+	attrs := []attribute.KeyValue{attribute.String("table", table), attribute.String("operation", op)}
+	if index != "" {
+		attrs = append(attrs, attribute.String("index", index))
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		trace.SpanFromContext(r.Context()).SetAttributes(attrs...)
+		next(w, r)
+	}
+}
+
+// withRateLimit rejects requests over the caller's rate limit on the table
+// with 429.
+func withRateLimit(th *tableHandler, next http.HandlerFunc) http.HandlerFunc {
+	if th.limiter == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := r.Context().Value(middleware.ClaimsKey).(jwt.MapClaims)
+		subject, _ := claims["sub"].(string)
+		if res, ok := th.limiter.Allow(ratelimit.ClientKey(r, subject)); ok {
+			ratelimit.WriteHeaders(w.Header(), res)
+			if !res.Allowed {
+				writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// withScanSlot rejects a table or index scan with 429 while the maximum
+// number of scans is already in flight.
+func (h *Handler) withScanSlot(next http.HandlerFunc) http.HandlerFunc {
+	if h.scanSlots == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case h.scanSlots <- struct{}{}:
+			defer func() { <-h.scanSlots }()
+		default:
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusTooManyRequests, "too many concurrent scans")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// withTenant scopes a request to the tenant named by the table's tenantClaim,
+// so the store only reads and writes that tenant's rows. Requests without the
+// claim are rejected.
+func withTenant(th *tableHandler, next http.HandlerFunc) http.HandlerFunc {
+	if th.config.TenantClaim == "" {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := r.Context().Value(middleware.ClaimsKey).(jwt.MapClaims)
+		tenant, ok := claims[th.config.TenantClaim].(string)
+		if !ok || tenant == "" {
+			writeError(w, http.StatusForbidden, "missing tenant claim")
+			return
 		}
+		next(w, r.WithContext(database.WithTenant(r.Context(), tenant)))
 	}
 }
@@ -1,12 +1,18 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/access"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/database"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/metrics"
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/model"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/tracing"
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/validate"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // handleGetItem handles GET for a single item.
@@ -42,7 +48,7 @@ func (h *Handler) handleGetItem(th *tableHandler) http.HandlerFunc {
 
 		data, err := h.store.GetItem(r.Context(), th.config.Name, pk, rkPtr)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to get item")
+			writeInternalError(w, r, "failed to get item", err)
 			return
 		}
 		if data == nil {
@@ -124,19 +130,90 @@ func (h *Handler) handlePutItem(th *tableHandler) http.HandlerFunc {
 			}
 		}
 
-		if err := th.validator.Validate(doc); err != nil {
+		// Tables with an ownerField or audit diffs, and callers with fields
+		// they may not write, replace items against the stored version: the
+		// owner field is set from the caller and only the owner may replace
+		// the item, locked fields keep their stored values, and the audit log
+		// records what was replaced.
+		locked := lockedFields(r, th)
+		conditional := th.config.OwnerField != "" || len(locked) > 0 || auditDiff(th)
+		var existing *database.ItemForUpdate
+		var stored map[string]any
+		if conditional {
+			owner := ""
+			if th.config.OwnerField != "" {
+				var ok bool
+				owner, ok = callerOwner(th, r)
+				if !ok {
+					writeError(w, http.StatusForbidden, "missing owner claim")
+					return
+				}
+				if bodyOwner, ok := doc[th.config.OwnerField]; ok {
+					if s, ok := bodyOwner.(string); !ok || s != owner {
+						writeError(w, http.StatusBadRequest, th.config.OwnerField+" in body does not match caller")
+						return
+					}
+				}
+				doc[th.config.OwnerField] = owner
+			}
+
+			existing, err = h.store.GetItemForUpdate(r.Context(), th.config.Name, pk, rkPtr)
+			if err != nil {
+				writeInternalError(w, r, "failed to get item", err)
+				return
+			}
+			if existing != nil {
+				stored = existing.Data
+			}
+			if th.config.OwnerField != "" && existing != nil && !ownedBy(th, stored, owner) {
+				writeError(w, http.StatusForbidden, "forbidden: item is owned by another caller")
+				return
+			}
+			if field := changedLockedField(r, th, locked, doc, stored, false); field != "" {
+				writeError(w, http.StatusForbidden, "forbidden: cannot write field "+field)
+				return
+			}
+			for _, field := range locked {
+				if v, ok := stored[field]; ok {
+					doc[field] = v
+				}
+			}
+		}
+
+		if err := th.validate(r.Context(), doc); err != nil {
 			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
 		stripped := model.StripKeys(doc, th.config.PrimaryKey.Field, rkField)
-		if err := h.store.PutItem(r.Context(), th.config.Name, pk, rkPtr, stripped); err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to put item")
-			return
+		ctx, audited := audit(r, th, access.OpPut, pk, rkPtr, stored, stripped)
+		if !conditional {
+			if err := h.store.PutItem(ctx, th.config.Name, pk, rkPtr, stripped); err != nil {
+				writeInternalError(w, r, "failed to put item", err)
+				return
+			}
+		} else {
+			var written bool
+			if existing == nil {
+				written, err = h.store.PutItemIfAbsent(ctx, th.config.Name, pk, rkPtr, stripped)
+			} else {
+				written, err = h.store.PutItemIfUnchanged(ctx, th.config.Name, pk, rkPtr, stripped, existing.UpdatedAt)
+			}
+			if err != nil {
+				writeInternalError(w, r, "failed to put item", err)
+				return
+			}
+			if !written {
+				metrics.WriteConflicts.WithLabelValues(th.config.Name, access.OpPut).Inc()
+				writeError(w, http.StatusConflict, "item was modified by another request")
+				return
+			}
 		}
 
+		audited()
+
 		result := model.InjectKeys(stripped, th.config.PrimaryKey.Field, pk, rkField, rkValue)
-		writeJSON(w, http.StatusOK, itemPayload(result))
+		writeJSON(w, http.StatusOK, itemPayload(maskFields(r, th, result)))
 	}
 }
 
@@ -215,7 +292,7 @@ func (h *Handler) handlePatchItem(th *tableHandler) http.HandlerFunc {
 
 		existing, err := h.store.GetItemForUpdate(r.Context(), th.config.Name, pk, rkPtr)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to get item")
+			writeInternalError(w, r, "failed to get item", err)
 			return
 		}
 		if existing == nil {
@@ -223,26 +300,53 @@ func (h *Handler) handlePatchItem(th *tableHandler) http.HandlerFunc {
 			return
 		}
 
+		if th.config.OwnerField != "" {
+			owner, ok := callerOwner(th, r)
+			if !ok {
+				writeError(w, http.StatusForbidden, "missing owner claim")
+				return
+			}
+			if !ownedBy(th, existing.Data, owner) {
+				writeError(w, http.StatusForbidden, "forbidden: item is owned by another caller")
+				return
+			}
+			if bodyOwner, ok := patch[th.config.OwnerField]; ok {
+				if s, ok := bodyOwner.(string); !ok || s != owner {
+					writeError(w, http.StatusBadRequest, th.config.OwnerField+" cannot be changed")
+					return
+				}
+			}
+		}
+
+		if field := changedLockedField(r, th, lockedFields(r, th), patch, existing.Data, true); field != "" {
+			writeError(w, http.StatusForbidden, "forbidden: cannot write field "+field)
+			return
+		}
+
 		merged := model.MergePatch(existing.Data, patch)
 		mergedWithKeys := model.InjectKeys(merged, th.config.PrimaryKey.Field, pk, rkField, rkValue)
 
-		if err := th.validator.Validate(mergedWithKeys); err != nil {
+		if err := th.validate(r.Context(), mergedWithKeys); err != nil {
 			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
 		stripped := model.StripKeys(mergedWithKeys, th.config.PrimaryKey.Field, rkField)
-		updated, err := h.store.PutItemIfUnchanged(r.Context(), th.config.Name, pk, rkPtr, stripped, existing.UpdatedAt)
+		ctx, audited := audit(r, th, access.OpPatch, pk, rkPtr, existing.Data, stripped)
+		updated, err := h.store.PutItemIfUnchanged(ctx, th.config.Name, pk, rkPtr, stripped, existing.UpdatedAt)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to put item")
+			writeInternalError(w, r, "failed to put item", err)
 			return
 		}
 		if !updated {
+			metrics.WriteConflicts.WithLabelValues(th.config.Name, access.OpPatch).Inc()
 			writeError(w, http.StatusConflict, "item was modified by another request")
 			return
 		}
 
-		writeJSON(w, http.StatusOK, itemPayload(mergedWithKeys))
+		audited()
+
+		writeJSON(w, http.StatusOK, itemPayload(maskFields(r, th, mergedWithKeys)))
 	}
 }
 
@@ -273,17 +377,89 @@ func (h *Handler) handleDeleteItem(th *tableHandler) http.HandlerFunc {
 			rkPtr = &rk
 		}
 
-		if err := h.store.DeleteItem(r.Context(), th.config.Name, pk, rkPtr); err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to delete item")
+		if th.config.OwnerField != "" || auditDiff(th) {
+			if h.deleteStoredItem(w, r, th, pk, rkPtr) {
+				w.WriteHeader(http.StatusNoContent)
+			}
 			return
 		}
 
+		ctx, audited := audit(r, th, access.OpDelete, pk, rkPtr, nil, nil)
+		deleted, err := h.store.DeleteItem(ctx, th.config.Name, pk, rkPtr)
+		if err != nil {
+			writeInternalError(w, r, "failed to delete item", err)
+			return
+		}
+		if deleted {
+			audited()
+		}
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
-// applyProjection applies field projection based on the fields query parameter.
+// deleteStoredItem deletes an item against its stored version, on tables
+// with an ownerField (only the owner may delete it) or audit diffs, whose
+// audit entry records the deleted item. It writes the error response and
+// returns false when the item cannot be deleted; deleting a missing item
+// succeeds.
+func (h *Handler) deleteStoredItem(w http.ResponseWriter, r *http.Request, th *tableHandler, pk string, rk *string) bool {
+	owner := ""
+	if th.config.OwnerField != "" {
+		var ok bool
+		owner, ok = callerOwner(th, r)
+		if !ok {
+			writeError(w, http.StatusForbidden, "missing owner claim")
+			return false
+		}
+	}
+
+	existing, err := h.store.GetItemForUpdate(r.Context(), th.config.Name, pk, rk)
+	if err != nil {
+		writeInternalError(w, r, "failed to get item", err)
+		return false
+	}
+	if existing == nil {
+		return true
+	}
+	if th.config.OwnerField != "" && !ownedBy(th, existing.Data, owner) {
+		writeError(w, http.StatusForbidden, "forbidden: item is owned by another caller")
+		return false
+	}
+
+	ctx, audited := audit(r, th, access.OpDelete, pk, rk, existing.Data, nil)
+	deleted, err := h.store.DeleteItemIfUnchanged(ctx, th.config.Name, pk, rk, existing.UpdatedAt)
+	if err != nil {
+		writeInternalError(w, r, "failed to delete item", err)
+		return false
+	}
+	if !deleted {
+		metrics.WriteConflicts.WithLabelValues(th.config.Name, access.OpDelete).Inc()
+		writeError(w, http.StatusConflict, "item was modified by another request")
+		return false
+	}
+	audited()
+	return true
+}
+
+// validate checks a document against the table schema in its own span,
+// counting failures.
+func (th *tableHandler) validate(ctx context.Context, doc map[string]any) error {
+	_, span := tracing.Start(ctx, "schema.validate", attribute.String("table", th.config.Name))
+	defer span.End()
+
+	err := th.validator.Validate(doc)
+	if err != nil {
+		metrics.SchemaValidationFailures.WithLabelValues(th.config.Name).Inc()
+		tracing.RecordError(span, err)
+	}
+	return err
+}
+
+// applyProjection hides the fields the caller may not read, then applies
+// field projection based on the fields query parameter.
 func applyProjection(r *http.Request, data map[string]any, th *tableHandler) map[string]any {
+	data = maskFields(r, th, data)
+
 	fieldsParam := r.URL.Query().Get("fields")
 	fields := model.ParseFieldsParam(fieldsParam)
 
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/ratelimit"
+)
+
+// Limits holds the per-table rate limiters and the scan slots, so Handlers
+// rebuilt on a config reload keep counting against the same buckets and
+// in-flight scans. A limiter or the scan slots are only replaced when their
+// settings change.
+type Limits struct {
+	mu      sync.Mutex
+	tables  map[string]tableLimit
+	scanCap int
+	slots   chan struct{}
+}
+
+type tableLimit struct {
+	config  *config.RateLimitConfig
+	limiter *ratelimit.Limiter
+}
+
+// NewLimits creates an empty Limits.
+func NewLimits() *Limits {
+	return &Limits{tables: make(map[string]tableLimit)}
+}
+
+// limiters returns each table's rate limiter, reusing the current one while
+// the table's limit is unchanged. Tables no longer configured are dropped.
+func (l *Limits) limiters(tables []config.TableConfig) map[string]*ratelimit.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	next := make(map[string]tableLimit, len(tables))
+	out := make(map[string]*ratelimit.Limiter, len(tables))
+	for _, t := range tables {
+		cur, ok := l.tables[t.Name]
+		if !ok || !reflect.DeepEqual(cur.config, t.RateLimit) {
+			cur = tableLimit{config: t.RateLimit, limiter: ratelimit.FromConfig(t.RateLimit, nil)}
+		}
+		next[t.Name] = cur
+		out[t.Name] = cur.limiter
+	}
+	l.tables = next
+	return out
+}
+
+// scanSlots returns the slots for at most n concurrent scans, reusing the
+// current ones while n is unchanged, or nil when n is not positive.
+func (l *Limits) scanSlots(n int) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if n != l.scanCap {
+		l.scanCap = n
+		l.slots = nil
+		if n > 0 {
+			l.slots = make(chan struct{}, n)
+		}
+	}
+	return l.slots
+}
@@ -23,6 +23,33 @@ type listMeta struct {
 	PreviousPageToken string `json:"previousPageToken,omitempty"`
 }
 
+// explainResponse is the JSON envelope for list requests with explain=true.
+type explainResponse struct {
+	Type      string   `json:"_type"`
+	Operation string   `json:"operation"`
+	Statement string   `json:"statement"`
+	Plan      []string `json:"plan"`
+}
+
+func explainRequested(r *http.Request) bool {
+	return r.URL.Query().Get("explain") == "true"
+}
+
+// writePlan writes the query plan of an explain=true request and reports
+// whether it did.
+func writePlan(w http.ResponseWriter, result *database.ListResult) bool {
+	if result.Plan == nil {
+		return false
+	}
+	writeJSON(w, http.StatusOK, explainResponse{
+		Type:      typeExplain,
+		Operation: result.Plan.Operation,
+		Statement: result.Plan.Statement,
+		Plan:      result.Plan.Lines,
+	})
+	return true
+}
+
 // handleListItems handles GET /v1/{table}/data/{pk}/_items.
 func (h *Handler) handleListItems(th *tableHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -36,12 +63,15 @@ func (h *Handler) handleListItems(th *tableHandler) http.HandlerFunc {
 			return
 		}
 
-		opts := parseListOptions(r)
+		opts := h.parseListOptions(r)
 		hasRK := th.config.RangeKey != nil
 
 		result, err := h.store.ListItems(r.Context(), th.config.Name, pk, hasRK, opts)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to list items")
+			writeInternalError(w, r, "failed to list items", err)
+			return
+		}
+		if writePlan(w, result) {
 			return
 		}
 
@@ -62,12 +92,15 @@ func (h *Handler) handleListItems(th *tableHandler) http.HandlerFunc {
 // handleScanTable handles GET /v1/{table}/_items.
 func (h *Handler) handleScanTable(th *tableHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		opts := parseListOptions(r)
+		opts := h.parseListOptions(r)
 		hasRK := th.config.RangeKey != nil
 
 		result, err := h.store.ScanTable(r.Context(), th.config.Name, hasRK, opts)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to scan table")
+			writeInternalError(w, r, "failed to scan table", err)
+			return
+		}
+		if writePlan(w, result) {
 			return
 		}
 
@@ -100,8 +133,9 @@ func (h *Handler) handleQueryIndex(th *tableHandler, idx config.IndexConfig) htt
 			}
 		}
 
-		opts := parseListOptions(r)
+		opts := h.parseListOptions(r)
 		iqc := database.IndexQueryConfig{
+			Name:    idx.Name,
 			PKField: idx.PrimaryKey.Field,
 		}
 		if idx.RangeKey != nil {
@@ -110,7 +144,10 @@ func (h *Handler) handleQueryIndex(th *tableHandler, idx config.IndexConfig) htt
 
 		result, err := h.store.QueryIndex(r.Context(), th.config.Name, iqc, indexPk, opts)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to query index")
+			writeInternalError(w, r, "failed to query index", err)
+			return
+		}
+		if writePlan(w, result) {
 			return
 		}
 
@@ -131,8 +168,9 @@ func (h *Handler) handleQueryIndex(th *tableHandler, idx config.IndexConfig) htt
 // handleScanIndex handles GET /v1/{table}/_index/{indexName}/_items.
 func (h *Handler) handleScanIndex(th *tableHandler, idx config.IndexConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		opts := parseListOptions(r)
+		opts := h.parseListOptions(r)
 		iqc := database.IndexQueryConfig{
+			Name:    idx.Name,
 			PKField: idx.PrimaryKey.Field,
 		}
 		if idx.RangeKey != nil {
@@ -141,7 +179,10 @@ func (h *Handler) handleScanIndex(th *tableHandler, idx config.IndexConfig) http
 
 		result, err := h.store.ScanIndex(r.Context(), th.config.Name, iqc, opts)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to scan index")
+			writeInternalError(w, r, "failed to scan index", err)
+			return
+		}
+		if writePlan(w, result) {
 			return
 		}
 
@@ -187,13 +228,14 @@ func (h *Handler) handleGetIndexItem(th *tableHandler, idx config.IndexConfig) h
 		}
 
 		iqc := database.IndexQueryConfig{
+			Name:    idx.Name,
 			PKField: idx.PrimaryKey.Field,
 			RKField: idx.RangeKey.Field,
 		}
 
 		result, err := h.store.GetItemByIndex(r.Context(), th.config.Name, iqc, indexPk, indexRk)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to get item by index")
+			writeInternalError(w, r, "failed to get item by index", err)
 			return
 		}
 		if result == nil {
@@ -216,7 +258,8 @@ func (h *Handler) handleGetIndexItem(th *tableHandler, idx config.IndexConfig) h
 }
 
 // parseListOptions extracts pagination and filter params from the request.
-func parseListOptions(r *http.Request) database.ListOptions {
+// The limit is capped at the server's maximum page size.
+func (h *Handler) parseListOptions(r *http.Request) database.ListOptions {
 	q := r.URL.Query()
 	opts := database.ListOptions{
 		PageToken:    q.Get("pageToken"),
@@ -225,10 +268,11 @@ func parseListOptions(r *http.Request) database.ListOptions {
 		RKGte:        q.Get("rkGte"),
 		RKLt:         q.Get("rkLt"),
 		RKLte:        q.Get("rkLte"),
+		Explain:      explainRequested(r),
 	}
 	if v := q.Get("limit"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
-			opts.Limit = n
+			opts.Limit = min(n, h.maxPageSize)
 		}
 	}
 	return opts
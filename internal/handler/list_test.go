@@ -3,10 +3,12 @@ package handler
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/database"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 func TestBuildListMetaAlwaysReturnsObject(t *testing.T) {
@@ -58,3 +60,78 @@ func TestQueryIndexValidatesConfiguredPattern(t *testing.T) {
 		t.Fatalf("expected 400, got %d", rec.Code)
 	}
 }
+
+func TestExplainRequiresAdminAccess(t *testing.T) {
+	rule := &config.AccessRule{Scopes: []string{"admin"}}
+	tables := []config.TableConfig{
+		{
+			Name: "items",
+			PrimaryKey: config.KeyConfig{
+				Field:   "itemId",
+				Pattern: "^[a-z]+$",
+			},
+			Schema: map[string]any{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]any{
+					"itemId": map[string]any{"type": "string"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		options  Options
+		claims   jwt.MapClaims
+		wantCode int
+		wantBody string
+	}{
+		{name: "explain disabled", options: Options{AdminAccess: rule}, claims: jwt.MapClaims{"scope": "admin"}, wantCode: http.StatusForbidden, wantBody: "explain is not enabled"},
+		{name: "missing scope", options: Options{AdminAccess: rule, Explain: true}, claims: jwt.MapClaims{"scope": "items"}, wantCode: http.StatusForbidden, wantBody: "forbidden"},
+		// The admin passes the gate and reaches key validation.
+		{name: "admin", options: Options{AdminAccess: rule, Explain: true}, claims: jwt.MapClaims{"scope": "admin"}, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := NewWithOptions(nil, tables, tt.options)
+			if err != nil {
+				t.Fatalf("failed to create handler: %v", err)
+			}
+			mux := http.NewServeMux()
+			h.SetupRoutes(mux)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/items/data/BAD/_items?explain=true", nil)
+			rec := serveWithClaims(mux, req, tt.claims)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Fatalf("expected body containing %q, got %s", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestWritePlan(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if writePlan(rec, &database.ListResult{}) {
+		t.Fatal("expected no plan to be written for a regular result")
+	}
+
+	plan := &database.QueryPlan{
+		Operation: "QueryIndex",
+		Statement: "SELECT pk, rk, data FROM \"items\" WHERE data->>'status' = $1 ORDER BY data->>'status' LIMIT $2",
+		Lines:     []string{"Limit  (actual time=0.010..0.011 rows=0 loops=1)", "  ->  Seq Scan on items"},
+	}
+	if !writePlan(rec, &database.ListResult{Plan: plan}) {
+		t.Fatal("expected the plan to be written")
+	}
+	body := rec.Body.String()
+	for _, want := range []string{`"_type":"explain"`, `"operation":"QueryIndex"`, `"plan":["Limit`, "Seq Scan on items"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected body containing %s, got %s", want, body)
+		}
+	}
+}
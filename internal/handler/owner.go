@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/database"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/middleware"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultOwnerClaim is the claim identifying the caller when a table sets
+// ownerField without ownerClaim.
+const defaultOwnerClaim = "sub"
+
+// callerOwner returns the caller's owner value for a table with an
+// ownerField, read from the table's ownerClaim.
+func callerOwner(th *tableHandler, r *http.Request) (string, bool) {
+	claim := th.config.OwnerClaim
+	if claim == "" {
+		claim = defaultOwnerClaim
+	}
+	claims, _ := r.Context().Value(middleware.ClaimsKey).(jwt.MapClaims)
+	owner, ok := claims[claim].(string)
+	return owner, ok && owner != ""
+}
+
+// ownedBy reports whether a stored item belongs to owner. Items without the
+// owner field belong to no one.
+func ownedBy(th *tableHandler, data map[string]any, owner string) bool {
+	v, ok := data[th.config.OwnerField].(string)
+	return ok && v == owner
+}
+
+// ownerIndex returns the index whose primary key is the table's ownerField,
+// which serves ownedBy=me listing.
+func ownerIndex(th *tableHandler) (config.IndexConfig, bool) {
+	if th.config.OwnerField == "" {
+		return config.IndexConfig{}, false
+	}
+	for _, idx := range th.config.Indexes {
+		if idx.PrimaryKey.Field == th.config.OwnerField {
+			return idx, true
+		}
+	}
+	return config.IndexConfig{}, false
+}
+
+// handleTableItems handles GET /v1/{table}/_items, dispatching ownedBy=me
+// requests to the owner index and everything else to the table scan. Either
+// handler may be nil when that mode is not available on the table.
+func handleTableItems(scan, owned http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("ownedBy") {
+			if owned == nil {
+				writeError(w, http.StatusBadRequest, "ownedBy requires an index on the table's ownerField")
+				return
+			}
+			owned(w, r)
+			return
+		}
+		if scan == nil {
+			writeError(w, http.StatusNotFound, "table scan is not enabled")
+			return
+		}
+		scan(w, r)
+	}
+}
+
+// handleOwnedItems handles GET /v1/{table}/_items?ownedBy=me by querying the
+// owner index with the caller's owner claim.
+func (h *Handler) handleOwnedItems(th *tableHandler, idx config.IndexConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ownedBy") != "me" {
+			writeError(w, http.StatusBadRequest, "ownedBy must be \"me\"")
+			return
+		}
+		owner, ok := callerOwner(th, r)
+		if !ok {
+			writeError(w, http.StatusForbidden, "missing owner claim")
+			return
+		}
+
+		opts := h.parseListOptions(r)
+		iqc := database.IndexQueryConfig{
+			Name:    idx.Name,
+			PKField: idx.PrimaryKey.Field,
+		}
+		if idx.RangeKey != nil {
+			iqc.RKField = idx.RangeKey.Field
+		}
+
+		result, err := h.store.QueryIndex(r.Context(), th.config.Name, iqc, owner, opts)
+		if err != nil {
+			writeInternalError(w, r, "failed to query index", err)
+			return
+		}
+		if writePlan(w, result) {
+			return
+		}
+
+		rkField := ""
+		if th.config.RangeKey != nil {
+			rkField = th.config.RangeKey.Field
+		}
+
+		items := projectIndexItems(r, result.Items, th, th.config.PrimaryKey.Field, rkField, idx)
+		writeJSON(w, http.StatusOK, listResponse{
+			Type:  typeItems,
+			Items: items,
+			Meta:  buildListMeta(result),
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/middleware"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newOwnedItemsMux(t *testing.T, indexes []config.IndexConfig, rules *config.AccessConfig) *http.ServeMux {
+	t.Helper()
+	h, err := New(nil, []config.TableConfig{
+		{
+			Name: "items",
+			PrimaryKey: config.KeyConfig{
+				Field:   "itemId",
+				Pattern: "^[a-z]+$",
+			},
+			OwnerField: "ownerId",
+			Schema: map[string]any{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]any{
+					"itemId":  map[string]any{"type": "string"},
+					"ownerId": map[string]any{"type": "string"},
+				},
+			},
+			Indexes: indexes,
+			Access:  rules,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	h.SetupRoutes(mux)
+	return mux
+}
+
+func serveWithClaims(mux *http.ServeMux, req *http.Request, claims jwt.MapClaims) *httptest.ResponseRecorder {
+	req = req.WithContext(context.WithValue(req.Context(), middleware.ClaimsKey, claims))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOwnedTableRejectsWriteWithoutOwnerClaim(t *testing.T) {
+	mux := newOwnedItemsMux(t, nil, nil)
+
+	req := httptest.NewRequest(http.MethodPut, "/v1/items/data/abc/_item", strings.NewReader(`{"itemId":"abc"}`))
+	rec := serveWithClaims(mux, req, jwt.MapClaims{"scope": "items"})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+}
+
+func TestOwnedTableRejectsBodyOwnerMismatch(t *testing.T) {
+	mux := newOwnedItemsMux(t, nil, nil)
+
+	req := httptest.NewRequest(http.MethodPut, "/v1/items/data/abc/_item", strings.NewReader(`{"itemId":"abc","ownerId":"someone-else"}`))
+	rec := serveWithClaims(mux, req, jwt.MapClaims{"sub": "user-1"})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "ownerId in body does not match caller") {
+		t.Fatalf("unexpected response: %s", rec.Body.String())
+	}
+}
+
+func TestOwnedByIsNotRoutedWithoutOwnerIndex(t *testing.T) {
+	mux := newOwnedItemsMux(t, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/items/_items?ownedBy=me", nil)
+	rec := serveWithClaims(mux, req, jwt.MapClaims{"sub": "user-1"})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without scan or owner index, got %d", rec.Code)
+	}
+}
+
+func TestOwnedByOnlyAcceptsMe(t *testing.T) {
+	mux := newOwnedItemsMux(t, []config.IndexConfig{
+		{Name: "by_owner", PrimaryKey: config.KeyConfig{Field: "ownerId"}},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/items/_items?ownedBy=user-2", nil)
+	rec := serveWithClaims(mux, req, jwt.MapClaims{"sub": "user-1"})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/items/_items", nil)
+	rec = serveWithClaims(mux, req, jwt.MapClaims{"sub": "user-1"})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a scan on a table without allowTableScan, got %d", rec.Code)
+	}
+}
+
+func TestOwnedByRequiresOwnerIndexAccess(t *testing.T) {
+	indexes := []config.IndexConfig{{Name: "by_owner", PrimaryKey: config.KeyConfig{Field: "ownerId"}}}
+
+	tests := []struct {
+		name  string
+		rules *config.AccessConfig
+	}{
+		{
+			name:  "index rule",
+			rules: &config.AccessConfig{Indexes: map[string]*config.AccessRule{"by_owner": {Scopes: []string{"owners"}}}},
+		},
+		{
+			name: "key field read rule",
+			rules: &config.AccessConfig{Fields: map[string]*config.FieldAccess{
+				"ownerId": {Read: &config.AccessRule{Scopes: []string{"owners"}}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newOwnedItemsMux(t, indexes, tt.rules)
+			req := httptest.NewRequest(http.MethodGet, "/v1/items/_items?ownedBy=me", nil)
+			rec := serveWithClaims(mux, req, jwt.MapClaims{"sub": "user-1"})
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("expected 403, got %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestTableRateLimit(t *testing.T) {
+	h, err := New(nil, []config.TableConfig{
+		{
+			Name: "items",
+			PrimaryKey: config.KeyConfig{
+				Field:   "itemId",
+				Pattern: "^[a-z]+$",
+			},
+			RateLimit: &config.RateLimitConfig{RequestsPerSecond: 0.001, Burst: 1},
+			Schema: map[string]any{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]any{
+					"itemId": map[string]any{"type": "string"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	mux := http.NewServeMux()
+	h.SetupRoutes(mux)
+
+	// An invalid key is rejected before the store is used, after the request
+	// has been counted.
+	get := func(sub string) *httptest.ResponseRecorder {
+		return serveWithClaims(mux, httptest.NewRequest(http.MethodGet, "/v1/items/data/ABC/_item", nil), jwt.MapClaims{"sub": sub})
+	}
+	if rec := get("alice"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	rec := get("alice")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" || rec.Header().Get("RateLimit-Limit") != "1" {
+		t.Fatalf("expected rate limit headers, got %v", rec.Header())
+	}
+	if rec := get("bob"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected another client to be allowed, got %d", rec.Code)
+	}
+}
+
+func TestScanSlots(t *testing.T) {
+	h, err := NewWithOptions(nil, nil, Options{MaxConcurrentScans: 1})
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	scan := h.withScanSlot(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	go scan(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	<-started
+
+	rec := httptest.NewRecorder()
+	scan(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 429 while a scan is in flight, got %d %v", rec.Code, rec.Header())
+	}
+	close(release)
+}
+
+func TestParseListOptionsCapsLimit(t *testing.T) {
+	h, err := NewWithOptions(nil, nil, Options{MaxPageSize: 100})
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+
+	opts := h.parseListOptions(httptest.NewRequest(http.MethodGet, "/?limit=100000", nil))
+	if opts.Limit != 100 {
+		t.Fatalf("expected limit capped at 100, got %d", opts.Limit)
+	}
+	opts = h.parseListOptions(httptest.NewRequest(http.MethodGet, "/?limit=20", nil))
+	if opts.Limit != 20 {
+		t.Fatalf("expected limit 20, got %d", opts.Limit)
+	}
+}
+
+func TestLimitsCarryOver(t *testing.T) {
+	tables := []config.TableConfig{{
+		Name:       "items",
+		PrimaryKey: config.KeyConfig{Field: "itemId", Pattern: "^[a-z]+$"},
+		RateLimit:  &config.RateLimitConfig{RequestsPerSecond: 1, Burst: 1},
+		Schema: map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties":           map[string]any{"itemId": map[string]any{"type": "string"}},
+		},
+	}}
+	limits := NewLimits()
+	build := func(tables []config.TableConfig, scans int) *Handler {
+		h, err := NewWithOptions(nil, tables, Options{MaxConcurrentScans: scans, Limits: limits})
+		if err != nil {
+			t.Fatalf("failed to create handler: %v", err)
+		}
+		return h
+	}
+
+	first := build(tables, 2)
+	second := build(tables, 2)
+	if first.tables["items"].limiter != second.tables["items"].limiter {
+		t.Fatal("expected an unchanged limit to keep its limiter")
+	}
+	if first.scanSlots != second.scanSlots {
+		t.Fatal("expected an unchanged scan cap to keep its slots")
+	}
+
+	changed := append([]config.TableConfig(nil), tables...)
+	changed[0].RateLimit = &config.RateLimitConfig{RequestsPerSecond: 2, Burst: 1}
+	third := build(changed, 3)
+	if third.tables["items"].limiter == second.tables["items"].limiter {
+		t.Fatal("expected a changed limit to get a new limiter")
+	}
+	if third.scanSlots == second.scanSlots || cap(third.scanSlots) != 3 {
+		t.Fatal("expected a changed scan cap to get new slots")
+	}
+}
@@ -2,14 +2,18 @@ package handler
 
 import (
 	"encoding/json"
+	"log/slog"
 	"maps"
 	"net/http"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/logging"
 )
 
 const (
-	typeItem  = "item"
-	typeItems = "items"
-	typeError = "error"
+	typeItem    = "item"
+	typeItems   = "items"
+	typeError   = "error"
+	typeExplain = "explain"
 )
 
 func writeJSON(w http.ResponseWriter, status int, data any) {
@@ -18,13 +22,26 @@ func writeJSON(w http.ResponseWriter, status int, data any) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// writeError writes an error body, with the request ID so callers can quote
+// it when reporting the error.
 func writeError(w http.ResponseWriter, status int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]string{
+	body := map[string]string{
 		"_type":  typeError,
 		"_error": message,
-	})
+	}
+	if id := w.Header().Get(logging.RequestIDHeader); id != "" {
+		body["_requestId"] = id
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeInternalError logs the cause of a 500 response, which the caller
+// never sees, and writes message.
+func writeInternalError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	slog.ErrorContext(r.Context(), message, "error", err)
+	writeError(w, http.StatusInternalServerError, message)
 }
 
 func itemPayload(data map[string]any) map[string]any {
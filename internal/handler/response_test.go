@@ -1,10 +1,16 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/logging"
 )
 
 func TestWriteErrorIncludesType(t *testing.T) {
@@ -27,6 +33,36 @@ func TestWriteErrorIncludesType(t *testing.T) {
 	}
 }
 
+func TestWriteInternalErrorLogsCauseAndRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	logger, err := logging.New(&logs, "info", "json")
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(prev)
+
+	handler := logging.Middleware(http.NewServeMux(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeInternalError(w, r, "failed to get item", errors.New("connection refused"))
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/v1/items/data/a/_item", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+	if body["_error"] != "failed to get item" || body["_requestId"] != "req-123" {
+		t.Fatalf("unexpected body %v", body)
+	}
+	if !strings.Contains(logs.String(), `"error":"connection refused","request_id":"req-123"`) {
+		t.Fatalf("cause not logged with the request ID:\n%s", logs.String())
+	}
+}
+
 func TestItemPayloadIncludesType(t *testing.T) {
 	payload := itemPayload(map[string]any{
 		"itemId": "a1",
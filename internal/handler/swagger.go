@@ -24,7 +24,7 @@ func (h *Handler) handleSwaggerUI() http.HandlerFunc {
 }
 
 func (h *Handler) handleOpenAPI(tableName string) http.HandlerFunc {
-	return func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
 		if h.openAPIDoc == nil {
 			writeError(w, http.StatusNotFound, "swagger is disabled")
 			return
@@ -32,7 +32,7 @@ func (h *Handler) handleOpenAPI(tableName string) http.HandlerFunc {
 
 		doc, err := h.openAPIDoc.YAML(tableName)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to generate OpenAPI")
+			writeInternalError(w, r, "failed to generate OpenAPI", err)
 			return
 		}
 
@@ -0,0 +1,93 @@
+// Package health serves the liveness and readiness probes and the admin
+// status report.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Probe paths, served without authentication.
+const (
+	LivePath  = "/healthz"
+	ReadyPath = "/readyz"
+)
+
+// checkTimeout bounds all readiness checks of one probe together.
+const checkTimeout = 5 * time.Second
+
+// IsProbePath reports whether path is a liveness or readiness probe.
+func IsProbePath(path string) bool {
+	return path == LivePath || path == ReadyPath
+}
+
+// Check reports why a dependency is not ready, or nil when it is.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the readiness checks.
+type Checker struct {
+	checks []namedCheck
+}
+
+// NewChecker creates a Checker without checks; it is always ready.
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Add adds a check reported under name. Checks run in the order added.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Register adds the probes to mux.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+LivePath, handleLive)
+	mux.HandleFunc("GET "+ReadyPath, c.handleReady)
+}
+
+type probeResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// handleLive answers as long as the process serves requests.
+func handleLive(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, probeResponse{Status: "ok"})
+}
+
+// handleReady runs every check and answers 503 when any fails. Probes are
+// unauthenticated, so the response names the failing checks only; the
+// causes are logged.
+func (c *Checker) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	resp := probeResponse{Status: "ready", Checks: make(map[string]string, len(c.checks))}
+	status := http.StatusOK
+	for _, nc := range c.checks {
+		if err := nc.check(ctx); err != nil {
+			slog.WarnContext(r.Context(), "readiness check failed", "check", nc.name, "error", err)
+			resp.Checks[nc.name] = "failed"
+			resp.Status = "not ready"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[nc.name] = "ok"
+	}
+	writeJSON(w, status, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
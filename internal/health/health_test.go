@@ -0,0 +1,110 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/database"
+)
+
+func serve(t *testing.T, h http.Handler, path string) (int, map[string]any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %s response: %v", path, err)
+	}
+	return rec.Code, body
+}
+
+func TestProbes(t *testing.T) {
+	dbErr := error(nil)
+	c := NewChecker()
+	c.Add("database", func(context.Context) error { return dbErr })
+	c.Add("jwks", func(context.Context) error { return nil })
+	mux := http.NewServeMux()
+	c.Register(mux)
+
+	if code, body := serve(t, mux, LivePath); code != http.StatusOK || body["status"] != "ok" {
+		t.Fatalf("healthz: got %d %v", code, body)
+	}
+
+	code, body := serve(t, mux, ReadyPath)
+	if code != http.StatusOK || body["status"] != "ready" {
+		t.Fatalf("readyz: got %d %v", code, body)
+	}
+
+	dbErr = errors.New("connection refused")
+	code, body = serve(t, mux, ReadyPath)
+	if code != http.StatusServiceUnavailable || body["status"] != "not ready" {
+		t.Fatalf("readyz with a failing check: got %d %v", code, body)
+	}
+	checks := body["checks"].(map[string]any)
+	if checks["database"] != "failed" || checks["jwks"] != "ok" {
+		t.Fatalf("unexpected checks: %v", checks)
+	}
+}
+
+func TestIsProbePath(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/healthz":          true,
+		"/readyz":           true,
+		"/healthz/":         false,
+		"/v1/items/healthz": false,
+	} {
+		if got := IsProbePath(path); got != want {
+			t.Errorf("IsProbePath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	executed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	opts := StatusOptions{
+		Version:    "v1.2.3",
+		ConfigHash: "abc",
+		Tables:     []string{"orders", "users"},
+		Migration: func(context.Context) (*database.MigrationStatus, error) {
+			return &database.MigrationStatus{
+				ConfigHash: "abc",
+				LastMigration: &database.MigrationRecord{
+					ExecutedAt: executed, ToolVersion: "v1.2.0", ConfigHash: "abc", Statements: 3,
+				},
+			}, nil
+		},
+	}
+
+	code, body := serve(t, StatusHandler(opts), "/status")
+	if code != http.StatusOK || body["version"] != "v1.2.3" || body["configHash"] != "abc" {
+		t.Fatalf("got %d %v", code, body)
+	}
+	if tables := body["tables"].([]any); len(tables) != 2 || tables[0] != "orders" {
+		t.Fatalf("unexpected tables: %v", tables)
+	}
+	m := body["migration"].(map[string]any)
+	if m["upToDate"] != true || m["configHash"] != "abc" {
+		t.Fatalf("unexpected migration: %v", m)
+	}
+	if last := m["lastMigration"].(map[string]any); last["toolVersion"] != "v1.2.0" || last["statements"] != float64(3) {
+		t.Fatalf("unexpected last migration: %v", last)
+	}
+
+	opts.Migration = func(context.Context) (*database.MigrationStatus, error) {
+		return nil, errors.New("connection refused")
+	}
+	code, body = serve(t, StatusHandler(opts), "/status")
+	if code != http.StatusOK || body["migrationError"] != "connection refused" || body["migration"] != nil {
+		t.Fatalf("got %d %v", code, body)
+	}
+
+	opts.Migration = nil
+	if _, body = serve(t, StatusHandler(opts), "/status"); body["migration"] != nil || body["migrationError"] != nil {
+		t.Fatalf("expected no migration state in schema-per-tenant mode, got %v", body)
+	}
+}
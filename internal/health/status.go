@@ -0,0 +1,66 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/database"
+)
+
+// StatusOptions describes the running service for the status report.
+type StatusOptions struct {
+	Version    string
+	ConfigHash string   // table-structure hash of the loaded config
+	Tables     []string // configured table names
+
+	// Migration reads the database's migration state. It is nil in
+	// schema-per-tenant mode, where each tenant schema is migrated on its
+	// own.
+	Migration func(ctx context.Context) (*database.MigrationStatus, error)
+}
+
+type statusResponse struct {
+	Version        string          `json:"version"`
+	ConfigHash     string          `json:"configHash"`
+	Tables         []string        `json:"tables"`
+	Migration      *migrationState `json:"migration,omitempty"`
+	MigrationError string          `json:"migrationError,omitempty"`
+}
+
+type migrationState struct {
+	*database.MigrationStatus
+	// UpToDate reports whether the stored hash matches the loaded config.
+	UpToDate bool `json:"upToDate"`
+}
+
+// StatusHandler reports the version, config hash, tables, and migration
+// state of the service. A failure to read the migration state is reported
+// in the body rather than failing the request, since that is when the
+// report is most needed.
+func StatusHandler(opts StatusOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := statusResponse{
+			Version:    opts.Version,
+			ConfigHash: opts.ConfigHash,
+			Tables:     opts.Tables,
+		}
+		if resp.Tables == nil {
+			resp.Tables = []string{}
+		}
+
+		if opts.Migration != nil {
+			ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+			defer cancel()
+			m, err := opts.Migration(ctx)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "reading migration state failed", "error", err)
+				resp.MigrationError = err.Error()
+			} else {
+				resp.Migration = &migrationState{MigrationStatus: m, UpToDate: m.ConfigHash == opts.ConfigHash}
+			}
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
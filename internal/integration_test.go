@@ -4,6 +4,7 @@ package internal_test
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -35,6 +36,8 @@ func TestMain(m *testing.M) {
 	testDB.Exec(`DROP TABLE IF EXISTS "items"`)
 	testDB.Exec(`DROP TABLE IF EXISTS "orders"`)
 	testDB.Exec(`DROP TABLE IF EXISTS _meta`)
+	testDB.Exec(`DROP TABLE IF EXISTS _migrations`)
+	testDB.Exec(`DROP TABLE IF EXISTS _audit`)
 
 	tables := testTables()
 
@@ -62,6 +65,8 @@ func TestMain(m *testing.M) {
 	testDB.Exec(`DROP TABLE IF EXISTS "items"`)
 	testDB.Exec(`DROP TABLE IF EXISTS "orders"`)
 	testDB.Exec(`DROP TABLE IF EXISTS _meta`)
+	testDB.Exec(`DROP TABLE IF EXISTS _migrations`)
+	testDB.Exec(`DROP TABLE IF EXISTS _audit`)
 	testDB.Close()
 
 	os.Exit(code)
@@ -109,6 +114,7 @@ func testTables() []config.TableConfig {
 				Pattern: `^[A-Za-z_][A-Za-z0-9._-]*$`,
 			},
 			AllowTableScan: false,
+			Audit:          &config.AuditConfig{Sink: "table", Diff: true},
 			Schema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -757,3 +763,50 @@ func TestListItems_Pagination(t *testing.T) {
 		t.Fatalf("expected at least 5 items across all pages, got %d", len(allItems))
 	}
 }
+
+func TestAudit_RecordsOrderWrites(t *testing.T) {
+	path := "/v1/orders/data/auditOrder/line1/_item"
+	resp := putItem(t, testServer, path, map[string]interface{}{
+		"orderId":    "auditOrder",
+		"lineId":     "line1",
+		"customerId": "cust1",
+		"amount":     10,
+	})
+	resp.Body.Close()
+	resp = patchItem(t, testServer, path, map[string]interface{}{
+		"orderId": "auditOrder",
+		"lineId":  "line1",
+		"amount":  12,
+	})
+	resp.Body.Close()
+	resp = deleteItem(t, testServer, path)
+	resp.Body.Close()
+
+	rk := "line1"
+	result, err := database.NewStore(testDB).QueryAudit(context.Background(), database.AuditQuery{
+		Table: "orders",
+		PK:    "auditOrder",
+		RK:    &rk,
+	})
+	if err != nil {
+		t.Fatalf("QueryAudit: %v", err)
+	}
+	if len(result.Entries) != 3 {
+		t.Fatalf("expected 3 audit entries, got %d", len(result.Entries))
+	}
+
+	// Newest first.
+	del, patch, put := result.Entries[0], result.Entries[1], result.Entries[2]
+	if del.Operation != "delete" || patch.Operation != "patch" || put.Operation != "put" {
+		t.Fatalf("unexpected operations: %s, %s, %s", del.Operation, patch.Operation, put.Operation)
+	}
+	if put.Before != nil || put.After["amount"] != float64(10) {
+		t.Fatalf("unexpected put diff: before=%v after=%v", put.Before, put.After)
+	}
+	if patch.Before["amount"] != float64(10) || patch.After["amount"] != float64(12) {
+		t.Fatalf("unexpected patch diff: before=%v after=%v", patch.Before, patch.After)
+	}
+	if del.Before["amount"] != float64(12) || del.After != nil {
+		t.Fatalf("unexpected delete diff: before=%v after=%v", del.Before, del.After)
+	}
+}
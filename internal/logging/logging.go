@@ -0,0 +1,70 @@
+// Package logging configures structured logging and writes the access log,
+// tagging every record logged for a request with the request's ID.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+)
+
+// New creates a logger writing to w at level (debug, info, warn, or error)
+// in format text or json. Empty values default to info and text.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+// Setup makes a logger for c, writing to stderr, the default for slog and
+// for the log package.
+func Setup(c config.LoggingConfig) error {
+	logger, err := New(os.Stderr, c.Level, c.Format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// contextHandler adds the request ID from the record's context, so every
+// record logged with a request's context can be matched to its access log
+// line.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info := infoFromContext(ctx); info != nil {
+		r.AddAttrs(slog.String("request_id", info.id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
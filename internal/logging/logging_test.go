@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// captureLogs makes a JSON logger writing to the returned buffer the default
+// for the test.
+func captureLogs(t *testing.T, level string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := New(&buf, level, "json")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestNew_Invalid(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "loud", "text"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if _, err := New(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestNew_Level(t *testing.T) {
+	buf := captureLogs(t, "warn")
+	slog.Info("hidden")
+	slog.Warn("shown")
+	if lines := bytes.Count(buf.Bytes(), []byte("\n")); lines != 1 {
+		t.Fatalf("expected one line at warn, got %d:\n%s", lines, buf.String())
+	}
+}
+
+func TestMiddleware_AccessLog(t *testing.T) {
+	buf := captureLogs(t, "info")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/widgets/data/{pk}/_item", func(w http.ResponseWriter, r *http.Request) {
+		SetSubject(r.Context(), "user-1")
+		slog.InfoContext(r.Context(), "inside")
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler := Middleware(mux, mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/widgets/data/abc/_item", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Fatalf("expected the caller's request ID to be echoed, got %q", got)
+	}
+
+	dec := json.NewDecoder(buf)
+	var inside, access map[string]any
+	if err := dec.Decode(&inside); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if err := dec.Decode(&access); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if inside["request_id"] != "abc-123" {
+		t.Errorf("records logged for the request should carry its ID, got %v", inside)
+	}
+	want := map[string]any{
+		"msg":        "request",
+		"method":     "GET",
+		"route":      "/v1/widgets/data/{pk}/_item",
+		"table":      "widgets",
+		"status":     float64(404),
+		"subject":    "user-1",
+		"request_id": "abc-123",
+	}
+	for k, v := range want {
+		if access[k] != v {
+			t.Errorf("access log %s = %v, want %v", k, access[k], v)
+		}
+	}
+	if _, ok := access["duration_ms"]; !ok {
+		t.Error("access log should record the duration")
+	}
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	captureLogs(t, "info")
+	handler := Middleware(http.NewServeMux(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if RequestID(r.Context()) == "" {
+			t.Error("expected a request ID in the context")
+		}
+	}))
+
+	for _, header := range []string{"", "has spaces", string(make([]byte, 200))} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(RequestIDHeader, header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		id := rec.Header().Get(RequestIDHeader)
+		if id == "" || id == header {
+			t.Errorf("header %q: expected a generated ID, got %q", header, id)
+		}
+	}
+}
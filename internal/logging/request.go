@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestIDHeader carries the request ID. A well-formed ID sent by the
+// caller is kept; otherwise one is generated. Either way it is echoed in the
+// response.
+const RequestIDHeader = "X-Request-Id"
+
+var requestIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._:+/=-]{1,128}$`)
+
+// requestInfo is shared by every handler serving a request, so the access
+// log written at the end sees what the inner handlers learned.
+type requestInfo struct {
+	id string
+
+	mu      sync.Mutex
+	subject string
+}
+
+type infoKey struct{}
+
+func infoFromContext(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(infoKey{}).(*requestInfo)
+	return info
+}
+
+// RequestID returns the ID of the request ctx belongs to, or "" outside a
+// request.
+func RequestID(ctx context.Context) string {
+	if info := infoFromContext(ctx); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// SetSubject records the authenticated caller of the request ctx belongs to
+// for its access log line.
+func SetSubject(ctx context.Context, subject string) {
+	if info := infoFromContext(ctx); info != nil {
+		info.mu.Lock()
+		info.subject = subject
+		info.mu.Unlock()
+	}
+}
+
+// Router finds the route pattern a request matches; *http.ServeMux is one.
+type Router interface {
+	Handler(r *http.Request) (h http.Handler, pattern string)
+}
+
+// Middleware assigns each request its ID, sets it on the response, and
+// writes one access log line when the request completes, with the method,
+// the mux route pattern the request matches, its table, status, duration,
+// and subject.
+func Middleware(mux Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if !requestIDRegexp.MatchString(id) {
+			id = rand.Text()
+		}
+		info := &requestInfo{id: id}
+		ctx := context.WithValue(r.Context(), infoKey{}, info)
+		w.Header().Set(RequestIDHeader, id)
+
+		sw := &StatusWriter{ResponseWriter: w, Status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		route, table := "", ""
+		if _, pattern := mux.Handler(r); pattern != "" {
+			if _, path, ok := strings.Cut(pattern, " "); ok {
+				pattern = path
+			}
+			route = pattern
+			if rest, ok := strings.CutPrefix(pattern, "/v1/"); ok {
+				table, _, _ = strings.Cut(rest, "/")
+			}
+		}
+
+		info.mu.Lock()
+		subject := info.subject
+		info.mu.Unlock()
+
+		slog.LogAttrs(ctx, slog.LevelInfo, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", route),
+			slog.String("table", table),
+			slog.Int("status", sw.Status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("subject", subject),
+		)
+	})
+}
+
+// StatusWriter records the status code written by a handler. Status starts
+// as the status to report when the handler writes none, usually
+// http.StatusOK.
+type StatusWriter struct {
+	http.ResponseWriter
+	Status      int
+	wroteHeader bool
+}
+
+func (w *StatusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.Status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *StatusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *StatusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
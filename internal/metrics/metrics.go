@@ -0,0 +1,87 @@
+// Package metrics defines the service's Prometheus metrics on the default
+// registry, which promhttp.Handler serves with the Go runtime and process
+// metrics.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Service metrics.
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "itemservicecentral_http_requests_total",
+		Help: "HTTP requests by table, route, method, and status.",
+	}, []string{"table", "route", "method", "status"})
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "itemservicecentral_http_request_duration_seconds",
+		Help:    "HTTP request latency by table, route, method, and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"table", "route", "method", "status"})
+	JWKSRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "itemservicecentral_jwks_refresh_total",
+		Help: "JWKS fetches by result (success or error).",
+	}, []string{"result"})
+	SchemaValidationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "itemservicecentral_schema_validation_failures_total",
+		Help: "Writes rejected because the item did not match the table schema.",
+	}, []string{"table"})
+	WriteConflicts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "itemservicecentral_write_conflicts_total",
+		Help: "Conditional writes rejected with 409 because the item changed concurrently.",
+	}, []string{"table", "operation"})
+)
+
+// RegisterDBStats registers the connection pool statistics of db on the
+// default registry, as the go_sql_* metrics labeled
+// db_name="itemservicecentral".
+func RegisterDBStats(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "itemservicecentral"))
+}
+
+// Instrument counts and times every request served by next. Requests are
+// labeled with the mux route they match, whether or not they reach it, so
+// requests rejected by authentication or rate limits are attributed to
+// their route too.
+func Instrument(mux logging.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, pattern := mux.Handler(r)
+		table, route := routeLabels(pattern)
+
+		sw := &logging.StatusWriter{ResponseWriter: w, Status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		status := strconv.Itoa(sw.Status)
+		HTTPRequests.WithLabelValues(table, route, r.Method, status).Inc()
+		HTTPDuration.WithLabelValues(table, route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeLabels splits a mux pattern such as "GET /v1/orders/data/{pk}/_item"
+// into the table and a route with the table replaced by {table}, so routes
+// can be compared across tables. Requests matching no route get the route
+// "unmatched".
+func routeLabels(pattern string) (table, route string) {
+	if pattern == "" {
+		return "", "unmatched"
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = path
+	}
+	parts := strings.Split(pattern, "/")
+	if len(parts) > 2 && parts[1] == "v1" {
+		table = parts[2]
+		parts[2] = "{table}"
+	}
+	return table, strings.Join(parts, "/")
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRouteLabels(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		wantTable string
+		wantRoute string
+	}{
+		{"GET /v1/orders/data/{pk}/{rk}/_item", "orders", "/v1/{table}/data/{pk}/{rk}/_item"},
+		{"GET /_admin/audit", "", "/_admin/audit"},
+		{"", "", "unmatched"},
+	}
+	for _, tt := range tests {
+		table, route := routeLabels(tt.pattern)
+		if table != tt.wantTable || route != tt.wantRoute {
+			t.Errorf("routeLabels(%q) = %q, %q; want %q, %q", tt.pattern, table, route, tt.wantTable, tt.wantRoute)
+		}
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/widgets/data/{pk}/_item", func(w http.ResponseWriter, r *http.Request) {})
+
+	// The wrapped handler rejects the request before it reaches the mux; it
+	// is still attributed to the route.
+	reject := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	handler := Instrument(mux, reject)
+
+	counter := HTTPRequests.WithLabelValues("widgets", "/v1/{table}/data/{pk}/_item", http.MethodGet, "401")
+	before := testutil.ToFloat64(counter)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/widgets/data/abc/_item", nil))
+	after := testutil.ToFloat64(counter)
+	if after != before+1 {
+		t.Fatalf("expected the request to be counted, got %v then %v", before, after)
+	}
+}
+
+func TestHandlerServesServiceAndRuntimeMetrics(t *testing.T) {
+	RegisterDBStats(&sql.DB{})
+	JWKSRefreshes.WithLabelValues("success").Inc()
+
+	rec := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`itemservicecentral_jwks_refresh_total{result="success"}`,
+		`go_sql_open_connections{db_name="itemservicecentral"}`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in the metrics, got:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// APIKeyHeader is the request header carrying an API key.
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyCacheTTL is how long a key found through the lookup function is
+// trusted before it is looked up again, so revoked keys stop working within
+// this time.
+const apiKeyCacheTTL = time.Minute
+
+// APIKey is an API key's identity and grants.
+type APIKey struct {
+	Name   string
+	Scopes []string
+	Claims map[string]string
+}
+
+// APIKeyLookup finds an active API key by the hex SHA-256 of the key. It
+// returns nil when there is no such key.
+type APIKeyLookup func(ctx context.Context, hash string) (*APIKey, error)
+
+// APIKeyAuthenticator resolves X-API-Key values to the claims the rest of the
+// request sees, in the same shape as JWT claims.
+type APIKeyAuthenticator struct {
+	keys   map[string]APIKey // by hash
+	lookup APIKeyLookup
+
+	mu    sync.Mutex
+	cache map[string]cachedAPIKey
+}
+
+type cachedAPIKey struct {
+	key     APIKey
+	expires time.Time
+}
+
+// NewAPIKeyAuthenticator creates an APIKeyAuthenticator for keys listed by
+// hash. lookup, when not nil, is consulted for keys not in the list.
+func NewAPIKeyAuthenticator(keys map[string]APIKey, lookup APIKeyLookup) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{
+		keys:   keys,
+		lookup: lookup,
+		cache:  make(map[string]cachedAPIKey),
+	}
+}
+
+// HashAPIKey returns the lowercase hex SHA-256 of an API key, the form keys
+// are configured and stored in.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticate returns the claims for an API key, or nil when the key is not
+// valid.
+func (a *APIKeyAuthenticator) authenticate(ctx context.Context, key string) (jwt.MapClaims, error) {
+	hash := HashAPIKey(key)
+	if k, ok := a.keys[hash]; ok {
+		return apiKeyClaims(k), nil
+	}
+	if a.lookup == nil {
+		return nil, nil
+	}
+
+	a.mu.Lock()
+	cached, ok := a.cache[hash]
+	a.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return apiKeyClaims(cached.key), nil
+	}
+
+	k, err := a.lookup(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if k == nil {
+		delete(a.cache, hash)
+		return nil, nil
+	}
+	// Only found keys are cached so junk keys cannot grow the cache.
+	a.cache[hash] = cachedAPIKey{key: *k, expires: time.Now().Add(apiKeyCacheTTL)}
+	return apiKeyClaims(*k), nil
+}
+
+// apiKeyClaims builds the claims an API key authenticates with: its scopes
+// as a space-delimited scope claim, sub set to "apikey:{name}" unless the
+// key's claims set it, and the key's other claims.
+func apiKeyClaims(k APIKey) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"sub":   "apikey:" + k.Name,
+		"scope": strings.Join(k.Scopes, " "),
+	}
+	for name, value := range k.Claims {
+		claims[name] = value
+	}
+	return claims
+}
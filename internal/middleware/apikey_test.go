@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func apiKeyRequest(key string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if key != "" {
+		req.Header.Set(APIKeyHeader, key)
+	}
+	return req
+}
+
+func TestAPIKey_ConfiguredKeySetsClaims(t *testing.T) {
+	m := &JWTMiddleware{enabled: false}
+	m.SetAPIKeys(NewAPIKeyAuthenticator(map[string]APIKey{
+		HashAPIKey("secret-key"): {Name: "batch", Scopes: []string{"items:read", "items:write"}, Claims: map[string]string{"org_id": "acme"}},
+	}, nil))
+
+	var got jwt.MapClaims
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = r.Context().Value(ClaimsKey).(jwt.MapClaims)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, apiKeyRequest("secret-key"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got["sub"] != "apikey:batch" || got["scope"] != "items:read items:write" || got["org_id"] != "acme" {
+		t.Fatalf("unexpected claims: %v", got)
+	}
+}
+
+func TestAPIKey_RejectsUnknownOrMissingKey(t *testing.T) {
+	m := &JWTMiddleware{enabled: false}
+	m.SetAPIKeys(NewAPIKeyAuthenticator(map[string]APIKey{HashAPIKey("secret-key"): {Name: "batch"}}, nil))
+	handler := m.Handler(okHandler)
+
+	for _, key := range []string{"wrong-key", ""} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, apiKeyRequest(key))
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("key %q: expected 401, got %d", key, rec.Code)
+		}
+	}
+}
+
+func TestAPIKey_JWTStillAccepted(t *testing.T) {
+	key := generateTestKey(t)
+	m := NewJWTMiddlewareWithKey(&key.PublicKey, "", "")
+	m.SetAPIKeys(NewAPIKeyAuthenticator(map[string]APIKey{HashAPIKey("secret-key"): {Name: "batch"}}, nil))
+	handler := m.Handler(okHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+createSignedToken(t, key, jwt.MapClaims{"sub": "user-1"}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a bearer token to be accepted, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, apiKeyRequest("secret-key"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected an API key to be accepted, got %d", rec.Code)
+	}
+}
+
+func TestAPIKey_LookupIsCachedForFoundKeys(t *testing.T) {
+	calls := 0
+	a := NewAPIKeyAuthenticator(nil, func(ctx context.Context, hash string) (*APIKey, error) {
+		calls++
+		if hash == HashAPIKey("stored-key") {
+			return &APIKey{Name: "stored", Scopes: []string{"items:read"}}, nil
+		}
+		return nil, nil
+	})
+
+	for range 3 {
+		claims, err := a.authenticate(context.Background(), "stored-key")
+		if err != nil || claims["sub"] != "apikey:stored" {
+			t.Fatalf("unexpected result: %v, %v", claims, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 lookup for a found key, got %d", calls)
+	}
+
+	for range 2 {
+		if claims, _ := a.authenticate(context.Background(), "junk"); claims != nil {
+			t.Fatalf("expected junk key to be rejected, got %v", claims)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("expected unknown keys not to be cached, got %d lookups", calls)
+	}
+}
+
+func TestAPIKey_LookupErrorReturns503(t *testing.T) {
+	m := &JWTMiddleware{enabled: false}
+	m.SetAPIKeys(NewAPIKeyAuthenticator(nil, func(ctx context.Context, hash string) (*APIKey, error) {
+		return nil, errors.New("connection refused")
+	}))
+
+	rec := httptest.NewRecorder()
+	m.Handler(okHandler).ServeHTTP(rec, apiKeyRequest("any-key"))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// clientCertClaims returns claims describing the request's verified client
+// certificate, or nil when it has none:
+//
+//	sub          the subject common name, or the first DNS or URI SAN
+//	cert_subject the full subject distinguished name
+//	cert_dns     DNS SANs
+//	cert_uris    URI SANs
+//	cert_emails  email SANs
+func clientCertClaims(r *http.Request) jwt.MapClaims {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+
+	claims := jwt.MapClaims{
+		"cert_subject": leaf.Subject.String(),
+	}
+	var uris []string
+	for _, u := range leaf.URIs {
+		uris = append(uris, u.String())
+	}
+	setListClaim(claims, "cert_dns", leaf.DNSNames)
+	setListClaim(claims, "cert_uris", uris)
+	setListClaim(claims, "cert_emails", leaf.EmailAddresses)
+
+	switch {
+	case leaf.Subject.CommonName != "":
+		claims["sub"] = leaf.Subject.CommonName
+	case len(leaf.DNSNames) > 0:
+		claims["sub"] = leaf.DNSNames[0]
+	case len(uris) > 0:
+		claims["sub"] = uris[0]
+	}
+	return claims
+}
+
+// setListClaim sets a non-empty list claim in the []any form of decoded JWT
+// array claims.
+func setListClaim(claims jwt.MapClaims, name string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	list := make([]any, len(values))
+	for i, v := range values {
+		list[i] = v
+	}
+	claims[name] = list
+}
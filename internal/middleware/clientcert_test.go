@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func clientCertRequest(cert *x509.Certificate) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	if cert != nil {
+		req.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return req
+}
+
+func TestClientCertIdentity(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/batch")
+	cert := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "batch-job", Organization: []string{"Example"}},
+		DNSNames: []string{"batch.internal"},
+		URIs:     []*url.URL{spiffe},
+	}
+
+	m := &JWTMiddleware{enabled: false}
+	m.SetClientCertIdentity(true)
+
+	var got jwt.MapClaims
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = r.Context().Value(ClaimsKey).(jwt.MapClaims)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, clientCertRequest(cert))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got["sub"] != "batch-job" || got["cert_subject"] != "CN=batch-job,O=Example" {
+		t.Fatalf("unexpected claims: %v", got)
+	}
+	if dns, _ := got["cert_dns"].([]any); len(dns) != 1 || dns[0] != "batch.internal" {
+		t.Fatalf("unexpected cert_dns: %v", got["cert_dns"])
+	}
+	if uris, _ := got["cert_uris"].([]any); len(uris) != 1 || uris[0] != "spiffe://example.org/batch" {
+		t.Fatalf("unexpected cert_uris: %v", got["cert_uris"])
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, clientCertRequest(nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a client certificate, got %d", rec.Code)
+	}
+}
+
+func TestClientCertIdentity_BearerTokenTakesPrecedence(t *testing.T) {
+	key := generateTestKey(t)
+	m := NewJWTMiddlewareWithKey(&key.PublicKey, "", "")
+	m.SetClientCertIdentity(true)
+
+	req := clientCertRequest(&x509.Certificate{Subject: pkix.Name{CommonName: "batch-job"}})
+	req.Header.Set("Authorization", "Bearer invalid")
+	rec := httptest.NewRecorder()
+	m.Handler(okHandler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the invalid bearer token to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,413 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/metrics"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// defaultJWKSTTL is how long keys are cached when the JWKS response has
+	// no Cache-Control max-age.
+	defaultJWKSTTL = 5 * time.Minute
+	// minJWKSTTL and maxJWKSTTL bound the max-age honored from the IdP.
+	minJWKSTTL = time.Minute
+	maxJWKSTTL = 24 * time.Hour
+	// minJWKSRefreshInterval is the least time between two JWKS fetches
+	// triggered by requests, so tokens with unknown kids cannot hammer the
+	// IdP.
+	minJWKSRefreshInterval = 30 * time.Second
+)
+
+// jwksFetcher fetches and caches JWKS keys from a remote URL, or from the
+// jwks_uri advertised by an OIDC issuer. Keys are kept after they expire
+// and served while the IdP cannot be reached.
+type jwksFetcher struct {
+	url    string // JWKS URL; resolved through discovery when empty
+	issuer string // OIDC issuer used for discovery
+	client *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]jwkPublicKey
+	expires     time.Time
+	lastAttempt time.Time // start of the latest fetch
+
+	refreshMu sync.Mutex // serializes fetches
+}
+
+// jwkPublicKey is a parsed JWKS key and the algorithm it is restricted to, if
+// the JWK named one.
+type jwkPublicKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+func newJWKSFetcher(url, issuer string) *jwksFetcher {
+	return &jwksFetcher{
+		url:    url,
+		issuer: issuer,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   make(map[string]jwkPublicKey),
+	}
+}
+
+// key returns the public key with the given kid for verifying a token signed
+// with alg. Unknown kids and expired keys trigger a refresh, at most once per
+// minJWKSRefreshInterval; within it unknown kids are rejected and expired keys
+// served without waiting for a fetch in progress. Expired keys are also
+// served if the refresh fails. The refresh is traced as part of the request in
+// ctx but not canceled with it, since concurrent requests wait for the same
+// fetch.
+func (f *jwksFetcher) key(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	f.mu.RLock()
+	k, ok := f.keys[kid]
+	expired := time.Now().After(f.expires)
+	refreshAllowed := time.Since(f.lastAttempt) >= minJWKSRefreshInterval
+	f.mu.RUnlock()
+
+	if !ok && !refreshAllowed {
+		return nil, fmt.Errorf("key %q not found in JWKS", kid)
+	}
+	if (!ok || expired) && refreshAllowed {
+		err := f.refreshRateLimited(context.WithoutCancel(ctx))
+		f.mu.RLock()
+		k, ok = f.keys[kid]
+		f.mu.RUnlock()
+		if !ok {
+			if err != nil {
+				return nil, fmt.Errorf("fetching JWKS: %w", err)
+			}
+			return nil, fmt.Errorf("key %q not found in JWKS", kid)
+		}
+	}
+
+	if k.alg != "" && k.alg != alg {
+		return nil, fmt.Errorf("key %q is for %s, not %s", kid, k.alg, alg)
+	}
+	if !keyMatchesAlg(k.key, alg) {
+		return nil, fmt.Errorf("key %q cannot verify %s", kid, alg)
+	}
+	return k.key, nil
+}
+
+// loaded reports whether a fetch has returned at least one key.
+func (f *jwksFetcher) loaded() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.keys) > 0
+}
+
+// refreshRateLimited refreshes the keys unless a fetch was attempted within
+// minJWKSRefreshInterval. Concurrent callers wait for one fetch.
+func (f *jwksFetcher) refreshRateLimited(ctx context.Context) error {
+	f.refreshMu.Lock()
+	defer f.refreshMu.Unlock()
+
+	f.mu.RLock()
+	recent := time.Since(f.lastAttempt) < minJWKSRefreshInterval
+	f.mu.RUnlock()
+	if recent {
+		return nil
+	}
+	return f.refreshLocked(ctx)
+}
+
+// run refreshes the keys in the background until ctx is done: first right
+// away, then ahead of each expiry. Failed fetches are retried every
+// minJWKSRefreshInterval while the cached keys keep being served.
+func (f *jwksFetcher) run(ctx context.Context) {
+	for {
+		f.refreshMu.Lock()
+		err := f.refreshLocked(ctx)
+		f.refreshMu.Unlock()
+
+		wait := minJWKSRefreshInterval
+		if err != nil {
+			slog.Warn("refreshing JWKS failed, serving cached keys", "error", err)
+		} else {
+			f.mu.RLock()
+			// Refresh when three quarters of the TTL has passed.
+			wait = max(time.Until(f.expires)*3/4, minJWKSRefreshInterval)
+			f.mu.RUnlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+type jwksResponse struct {
+	Keys []jwkKey `json:"keys"`
+}
+
+type jwkKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// refreshLocked fetches the JWKS and replaces the cached keys. The caller
+// must hold refreshMu.
+func (f *jwksFetcher) refreshLocked(ctx context.Context) (err error) {
+	f.mu.Lock()
+	f.lastAttempt = time.Now()
+	f.mu.Unlock()
+	ctx, span := tracing.Start(ctx, "jwks.refresh")
+	defer func() {
+		result := "success"
+		if err != nil {
+			result = "error"
+			tracing.RecordError(span, err)
+		}
+		metrics.JWKSRefreshes.WithLabelValues(result).Inc()
+		span.End()
+	}()
+
+	if f.url == "" {
+		url, err := f.discover(ctx)
+		if err != nil {
+			return err
+		}
+		f.url = url
+	}
+
+	span.SetAttributes(attribute.String("jwks.url", f.url))
+	resp, err := f.get(ctx, f.url)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading JWKS response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS URL returned status %d", resp.StatusCode)
+	}
+
+	var jwks jwksResponse
+	if err := json.Unmarshal(body, &jwks); err != nil {
+		return fmt.Errorf("parsing JWKS response: %w", err)
+	}
+
+	keys := make(map[string]jwkPublicKey)
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := parseJWK(k)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = jwkPublicKey{key: pub, alg: k.Alg}
+	}
+
+	f.mu.Lock()
+	f.keys = keys
+	f.expires = time.Now().Add(cacheTTL(resp.Header.Get("Cache-Control")))
+	f.mu.Unlock()
+
+	return nil
+}
+
+// discover resolves the JWKS URL from the issuer's OIDC discovery document.
+func (f *jwksFetcher) discover(ctx context.Context) (string, error) {
+	if f.issuer == "" {
+		return "", fmt.Errorf("no JWKS URL or issuer configured")
+	}
+	discoveryURL := strings.TrimSuffix(f.issuer, "/") + "/.well-known/openid-configuration"
+
+	resp, err := f.get(ctx, discoveryURL)
+	if err != nil {
+		return "", fmt.Errorf("fetching OIDC discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OIDC discovery returned status %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", fmt.Errorf("parsing OIDC discovery document: %w", err)
+	}
+	if doc.Issuer != f.issuer {
+		return "", fmt.Errorf("OIDC discovery issuer %q does not match %q", doc.Issuer, f.issuer)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("OIDC discovery document has no jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
+
+// get fetches url, propagating the trace in ctx to the identity provider.
+func (f *jwksFetcher) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	tracing.Inject(ctx, req.Header)
+	return f.client.Do(req)
+}
+
+// cacheTTL returns how long to cache a JWKS response with the given
+// Cache-Control header: its max-age clamped to [minJWKSTTL, maxJWKSTTL], or
+// defaultJWKSTTL without one.
+func cacheTTL(cacheControl string) time.Duration {
+	for directive := range strings.SplitSeq(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+		if err != nil || seconds < 0 {
+			break
+		}
+		return min(max(time.Duration(seconds)*time.Second, minJWKSTTL), maxJWKSTTL)
+	}
+	return defaultJWKSTTL
+}
+
+// parseJWK parses an RSA, EC (P-256, P-384, P-521), or OKP (Ed25519) public
+// key. Other key types are rejected.
+func parseJWK(k jwkKey) (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		return parseRSAPublicKey(k)
+	case "EC":
+		return parseECPublicKey(k)
+	case "OKP":
+		return parseOKPPublicKey(k)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func parseRSAPublicKey(k jwkKey) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("decoding modulus: %w", err)
+	}
+
+	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("decoding exponent: %w", err)
+	}
+
+	n := new(big.Int).SetBytes(nBytes)
+	e := new(big.Int).SetBytes(eBytes)
+
+	return &rsa.PublicKey{
+		N: n,
+		E: int(e.Int64()),
+	}, nil
+}
+
+func parseECPublicKey(k jwkKey) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported EC curve %q", k.Crv)
+	}
+
+	xBytes, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("decoding x coordinate: %w", err)
+	}
+	yBytes, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("decoding y coordinate: %w", err)
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+	if len(xBytes) != size || len(yBytes) != size {
+		return nil, fmt.Errorf("coordinates must be %d bytes for %s", size, k.Crv)
+	}
+
+	point := make([]byte, 0, 1+2*size)
+	point = append(point, 4) // uncompressed
+	point = append(point, xBytes...)
+	point = append(point, yBytes...)
+
+	pub, err := ecdsa.ParseUncompressedPublicKey(curve, point)
+	if err != nil {
+		return nil, fmt.Errorf("parsing EC point: %w", err)
+	}
+	return pub, nil
+}
+
+func parseOKPPublicKey(k jwkKey) (ed25519.PublicKey, error) {
+	if k.Crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported OKP curve %q", k.Crv)
+	}
+
+	xBytes, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("decoding x coordinate: %w", err)
+	}
+	if len(xBytes) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("Ed25519 key must be %d bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(xBytes), nil
+}
+
+// keyMatchesAlg reports whether key is the right type (and curve) to verify
+// signatures made with alg.
+func keyMatchesAlg(key crypto.PublicKey, alg string) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		switch alg {
+		case "ES256":
+			return k.Curve == elliptic.P256()
+		case "ES384":
+			return k.Curve == elliptic.P384()
+		case "ES512":
+			return k.Curve == elliptic.P521()
+		}
+		return false
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	default:
+		return false
+	}
+}
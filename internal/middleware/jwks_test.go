@@ -0,0 +1,325 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func serveJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func ecJWK(kid string, pub *ecdsa.PublicKey, crv string) map[string]string {
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": crv,
+		"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+		"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+	}
+}
+
+func okpJWK(kid string, pub ed25519.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "OKP",
+		"kid": kid,
+		"crv": "Ed25519",
+		"x":   base64.RawURLEncoding.EncodeToString(pub),
+	}
+}
+
+func signWithKid(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return signed
+}
+
+func authStatus(m *JWTMiddleware, token string) int {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	m.Handler(okHandler).ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestJWKS_AsymmetricAlgorithms(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating P-256 key: %v", err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating P-384 key: %v", err)
+	}
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating Ed25519 key: %v", err)
+	}
+
+	url := serveJWKS(t,
+		ecJWK("ec256", &p256.PublicKey, "P-256"),
+		ecJWK("ec384", &p384.PublicKey, "P-384"),
+		okpJWK("ed", edPub),
+	)
+	m, err := NewJWTMiddleware(true, JWTOptions{JWKSURL: url, Algorithms: []string{"ES256", "ES384", "EdDSA"}})
+	if err != nil {
+		t.Fatalf("creating middleware: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "ES256", token: signWithKid(t, jwt.SigningMethodES256, "ec256", p256), want: http.StatusOK},
+		{name: "ES384", token: signWithKid(t, jwt.SigningMethodES384, "ec384", p384), want: http.StatusOK},
+		{name: "EdDSA", token: signWithKid(t, jwt.SigningMethodEdDSA, "ed", edPriv), want: http.StatusOK},
+		{name: "curve does not match alg", token: signWithKid(t, jwt.SigningMethodES384, "ec256", p384), want: http.StatusUnauthorized},
+		{name: "key type does not match alg", token: signWithKid(t, jwt.SigningMethodES256, "ed", p256), want: http.StatusUnauthorized},
+		{name: "unknown kid", token: signWithKid(t, jwt.SigningMethodES256, "missing", p256), want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := authStatus(m, tt.token); got != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestJWKS_AlgorithmNotAllowed(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating P-256 key: %v", err)
+	}
+	url := serveJWKS(t, ecJWK("ec256", &p256.PublicKey, "P-256"))
+
+	m, err := NewJWTMiddleware(true, JWTOptions{JWKSURL: url})
+	if err != nil {
+		t.Fatalf("creating middleware: %v", err)
+	}
+	if got := authStatus(m, signWithKid(t, jwt.SigningMethodES256, "ec256", p256)); got != http.StatusUnauthorized {
+		t.Fatalf("expected ES256 to be rejected with the default algorithms, got %d", got)
+	}
+}
+
+func TestHS256SharedSecret(t *testing.T) {
+	secret := []byte("internal-service-secret")
+
+	m, err := NewJWTMiddleware(true, JWTOptions{Algorithms: []string{"HS256"}, HMACSecret: secret})
+	if err != nil {
+		t.Fatalf("creating middleware: %v", err)
+	}
+
+	if got := authStatus(m, signWithKid(t, jwt.SigningMethodHS256, "", secret)); got != http.StatusOK {
+		t.Fatalf("expected 200, got %d", got)
+	}
+	if got := authStatus(m, signWithKid(t, jwt.SigningMethodHS256, "", []byte("wrong"))); got != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for the wrong secret, got %d", got)
+	}
+}
+
+func TestNewJWTMiddleware_RequiresKeys(t *testing.T) {
+	if _, err := NewJWTMiddleware(true, JWTOptions{Algorithms: []string{"HS256"}}); err == nil {
+		t.Fatal("expected an error for HS256 without a secret")
+	}
+	if _, err := NewJWTMiddleware(true, JWTOptions{Algorithms: []string{"ES256"}}); err == nil {
+		t.Fatal("expected an error for ES256 without a JWKS URL")
+	}
+	if _, err := NewJWTMiddleware(true, JWTOptions{JWKSURL: "http://example.com", Algorithms: []string{"none"}}); err == nil {
+		t.Fatal("expected an error for alg none")
+	}
+}
+
+func TestCheckKeys(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating P-256 key: %v", err)
+	}
+	url := serveJWKS(t, ecJWK("ec256", &p256.PublicKey, "P-256"))
+
+	m, err := NewJWTMiddleware(true, JWTOptions{JWKSURL: url, Algorithms: []string{"ES256"}})
+	if err != nil {
+		t.Fatalf("creating middleware: %v", err)
+	}
+	if err := m.CheckKeys(context.Background()); err == nil {
+		t.Fatal("expected an error before the first fetch")
+	}
+	if got := authStatus(m, signWithKid(t, jwt.SigningMethodES256, "ec256", p256)); got != http.StatusOK {
+		t.Fatalf("expected 200, got %d", got)
+	}
+	if err := m.CheckKeys(context.Background()); err != nil {
+		t.Fatalf("expected keys to be loaded, got %v", err)
+	}
+
+	hmac, err := NewJWTMiddleware(true, JWTOptions{Algorithms: []string{"HS256"}, HMACSecret: []byte("s")})
+	if err != nil {
+		t.Fatalf("creating middleware: %v", err)
+	}
+	if err := hmac.CheckKeys(context.Background()); err != nil {
+		t.Fatalf("expected no JWKS requirement for HS256, got %v", err)
+	}
+}
+
+func TestJWKS_OIDCDiscovery(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating P-256 key: %v", err)
+	}
+
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{ecJWK("ec256", &p256.PublicKey, "P-256")}})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	issuer = srv.URL
+
+	m, err := NewJWTMiddleware(true, JWTOptions{Issuer: issuer, Algorithms: []string{"ES256"}})
+	if err != nil {
+		t.Fatalf("creating middleware: %v", err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"iss": issuer, "exp": time.Now().Add(time.Hour).Unix()})
+	token.Header["kid"] = "ec256"
+	signed, err := token.SignedString(p256)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	if got := authStatus(m, signed); got != http.StatusOK {
+		t.Fatalf("expected 200, got %d", got)
+	}
+}
+
+func TestJWKS_ServesStaleKeysAndRateLimitsUnknownKids(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating P-256 key: %v", err)
+	}
+
+	fetches := 0
+	down := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=600")
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{ecJWK("ec256", &p256.PublicKey, "P-256")}})
+	}))
+	defer srv.Close()
+
+	f := newJWKSFetcher(srv.URL, "")
+	if _, err := f.key(context.Background(), "ec256", "ES256"); err != nil {
+		t.Fatalf("loading key: %v", err)
+	}
+	if ttl := time.Until(f.expires); ttl < 9*time.Minute {
+		t.Fatalf("expected max-age=600 to be honored, got %v", ttl)
+	}
+
+	// Unknown kids within the refresh interval do not refetch.
+	for range 5 {
+		if _, err := f.key(context.Background(), "junk", "ES256"); err == nil {
+			t.Fatal("expected an unknown kid to be rejected")
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("expected 1 fetch, got %d", fetches)
+	}
+
+	// Expired keys are still served while the IdP is down.
+	down = true
+	f.mu.Lock()
+	f.expires = time.Now().Add(-time.Minute)
+	f.lastAttempt = time.Time{}
+	f.mu.Unlock()
+
+	if _, err := f.key(context.Background(), "ec256", "ES256"); err != nil {
+		t.Fatalf("expected the stale key to be served, got %v", err)
+	}
+	if fetches != 2 {
+		t.Fatalf("expected the expired key to trigger a refresh, got %d fetches", fetches)
+	}
+}
+
+func TestJWKS_UnknownKidDoesNotWaitForFetch(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating P-256 key: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{ecJWK("ec256", &p256.PublicKey, "P-256")}})
+	}))
+	defer srv.Close()
+
+	f := newJWKSFetcher(srv.URL, "")
+	if _, err := f.key(context.Background(), "ec256", "ES256"); err != nil {
+		t.Fatalf("loading key: %v", err)
+	}
+
+	// A fetch in progress holds refreshMu; within the refresh interval an
+	// unknown kid is rejected without waiting for it.
+	f.refreshMu.Lock()
+	defer f.refreshMu.Unlock()
+	done := make(chan error, 1)
+	go func() {
+		_, err := f.key(context.Background(), "junk", "ES256")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an unknown kid to be rejected")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("unknown kid waited for the fetch in progress")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{header: "", want: defaultJWKSTTL},
+		{header: "public, max-age=3600", want: time.Hour},
+		{header: "max-age=5", want: minJWKSTTL},
+		{header: "max-age=9999999", want: maxJWKSTTL},
+		{header: "no-cache", want: defaultJWKSTTL},
+	}
+	for _, tt := range tests {
+		if got := cacheTTL(tt.header); got != tt.want {
+			t.Errorf("cacheTTL(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
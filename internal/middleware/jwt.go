@@ -3,16 +3,15 @@ package middleware
 import (
 	"context"
 	"crypto/rsa"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
-	"math/big"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
-	"time"
 
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/logging"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/tracing"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -23,40 +22,145 @@ const ClaimsKey contextKey = "claims"
 
 // JWTMiddleware validates JWT tokens on incoming requests.
 type JWTMiddleware struct {
-	enabled  bool
+	enabled    bool
+	algorithms []string
+	keyFunc    func(ctx context.Context, token *jwt.Token) (any, error)
+	jwks       *jwksFetcher
+	apiKeys    *APIKeyAuthenticator
+	certs      bool // accept verified client certificates as identity
+
+	mu       sync.RWMutex // guards issuer and audience, which change on config reload
 	issuer   string
 	audience string
-	keyFunc  jwt.Keyfunc
 }
 
-// NewJWTMiddleware creates a JWTMiddleware from the application's JWTConfig.
-// When enabled, it fetches JWKS keys from the configured URL.
-func NewJWTMiddleware(enabled bool, jwksURL, issuer, audience string) (*JWTMiddleware, error) {
+// DefaultJWTAlgorithms are the signing algorithms accepted when none are
+// configured.
+var DefaultJWTAlgorithms = []string{"RS256"}
+
+// JWTOptions configures token validation for NewJWTMiddleware.
+type JWTOptions struct {
+	JWKSURL    string   // JWKS endpoint for asymmetric keys
+	Issuer     string   // expected iss claim, if set; without JWKSURL, its OIDC discovery document names the JWKS
+	Audience   string   // expected aud claim, if set
+	Algorithms []string // accepted alg values; defaults to DefaultJWTAlgorithms
+	HMACSecret []byte   // shared secret for HS256
+}
+
+// NewJWTMiddleware creates a JWTMiddleware. When enabled, asymmetric keys
+// are fetched from the JWKS URL and HS256 tokens are verified with the
+// shared secret.
+func NewJWTMiddleware(enabled bool, opts JWTOptions) (*JWTMiddleware, error) {
 	m := &JWTMiddleware{
-		enabled:  enabled,
-		issuer:   issuer,
-		audience: audience,
+		enabled:    enabled,
+		issuer:     opts.Issuer,
+		audience:   opts.Audience,
+		algorithms: opts.Algorithms,
+	}
+	if len(m.algorithms) == 0 {
+		m.algorithms = DefaultJWTAlgorithms
+	}
+
+	if !enabled {
+		return m, nil
+	}
+
+	needsJWKS := false
+	for _, alg := range m.algorithms {
+		method := jwt.GetSigningMethod(alg)
+		if method == nil || method == jwt.SigningMethodNone {
+			return nil, fmt.Errorf("unsupported jwt algorithm %q", alg)
+		}
+		if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+			if len(opts.HMACSecret) == 0 {
+				return nil, fmt.Errorf("jwt algorithm %s requires a shared secret", alg)
+			}
+			continue
+		}
+		needsJWKS = true
 	}
 
-	if enabled {
-		if jwksURL == "" {
-			return nil, fmt.Errorf("jwt is enabled but jwksUrl is not set")
+	var jwks *jwksFetcher
+	if needsJWKS {
+		if opts.JWKSURL == "" && opts.Issuer == "" {
+			return nil, fmt.Errorf("jwt is enabled but neither jwksUrl nor issuer is set")
 		}
-		jwks := newJWKSFetcher(jwksURL)
-		m.keyFunc = jwks.keyFunc
+		jwks = newJWKSFetcher(opts.JWKSURL, opts.Issuer)
+		m.jwks = jwks
+	}
+
+	secret := opts.HMACSecret
+	m.keyFunc = func(ctx context.Context, token *jwt.Token) (any, error) {
+		// Parse has already checked the alg against m.algorithms; the key
+		// type must follow the alg so an HMAC token is never verified with a
+		// public key or the reverse.
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			return secret, nil
+		}
+		if jwks == nil {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return jwks.key(ctx, kid, token.Method.Alg())
 	}
 
 	return m, nil
 }
 
+// SetAPIKeys accepts API keys in the X-API-Key header. Requests with the
+// header authenticate with the key instead of a JWT; when JWT validation is
+// disabled, the key is required.
+func (m *JWTMiddleware) SetAPIKeys(a *APIKeyAuthenticator) {
+	m.apiKeys = a
+}
+
+// SetClientCertIdentity accepts a verified TLS client certificate as the
+// caller's identity for requests without an Authorization or X-API-Key
+// header.
+func (m *JWTMiddleware) SetClientCertIdentity(enabled bool) {
+	m.certs = enabled
+}
+
+// StartKeyRefresh loads the JWKS keys in the background and keeps them
+// fresh until ctx is done. Without it, keys are fetched on demand.
+func (m *JWTMiddleware) StartKeyRefresh(ctx context.Context) {
+	if m.jwks != nil {
+		go m.jwks.run(ctx)
+	}
+}
+
+// SetExpectedClaims replaces the iss and aud values tokens must carry; an
+// empty value is not checked. It may be called while requests are served.
+func (m *JWTMiddleware) SetExpectedClaims(issuer, audience string) {
+	m.mu.Lock()
+	m.issuer, m.audience = issuer, audience
+	m.mu.Unlock()
+}
+
+func (m *JWTMiddleware) expectedClaims() (issuer, audience string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.issuer, m.audience
+}
+
+// CheckKeys reports an error while JWT validation needs JWKS keys and none
+// have been loaded yet, so readiness can wait for the first fetch.
+func (m *JWTMiddleware) CheckKeys(context.Context) error {
+	if m.jwks != nil && !m.jwks.loaded() {
+		return fmt.Errorf("JWKS keys not loaded")
+	}
+	return nil
+}
+
 // NewJWTMiddlewareWithKey creates a JWTMiddleware using a static RSA public key.
 // This is intended for testing.
 func NewJWTMiddlewareWithKey(key *rsa.PublicKey, issuer, audience string) *JWTMiddleware {
 	return &JWTMiddleware{
-		enabled:  true,
-		issuer:   issuer,
-		audience: audience,
-		keyFunc: func(token *jwt.Token) (any, error) {
+		enabled:    true,
+		issuer:     issuer,
+		audience:   audience,
+		algorithms: DefaultJWTAlgorithms,
+		keyFunc: func(_ context.Context, token *jwt.Token) (any, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
@@ -65,11 +169,44 @@ func NewJWTMiddlewareWithKey(key *rsa.PublicKey, issuer, audience string) *JWTMi
 	}
 }
 
-// Handler returns an http.Handler that validates the JWT on each request.
+// Handler returns an http.Handler that authenticates each request with an
+// X-API-Key header, a bearer JWT, or a verified client certificate, as
+// configured.
 func (m *JWTMiddleware) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.apiKeys != nil {
+			if key := r.Header.Get(APIKeyHeader); key != "" {
+				claims, err := m.apiKeys.authenticate(r.Context(), key)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "api key lookup failed", "error", err)
+					writeJSONError(w, http.StatusServiceUnavailable, "api key lookup failed")
+					return
+				}
+				if claims == nil {
+					writeJSONError(w, http.StatusUnauthorized, "invalid api key")
+					return
+				}
+				ctx := withClaims(r.Context(), claims)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+
+		if m.certs && r.Header.Get("Authorization") == "" {
+			if claims := clientCertClaims(r); claims != nil {
+				ctx := withClaims(r.Context(), claims)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+
+		if !m.enabled && (m.apiKeys != nil || m.certs) {
+			writeJSONError(w, http.StatusUnauthorized, "missing credentials")
+			return
+		}
+
 		if !m.enabled {
-			ctx := context.WithValue(r.Context(), ClaimsKey, jwt.MapClaims{})
+			ctx := withClaims(r.Context(), jwt.MapClaims{})
 			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
@@ -89,16 +226,23 @@ func (m *JWTMiddleware) Handler(next http.Handler) http.Handler {
 		tokenString := parts[1]
 
 		parserOpts := []jwt.ParserOption{
-			jwt.WithValidMethods([]string{"RS256"}),
+			jwt.WithValidMethods(m.algorithms),
 		}
-		if m.issuer != "" {
-			parserOpts = append(parserOpts, jwt.WithIssuer(m.issuer))
+		issuer, audience := m.expectedClaims()
+		if issuer != "" {
+			parserOpts = append(parserOpts, jwt.WithIssuer(issuer))
 		}
-		if m.audience != "" {
-			parserOpts = append(parserOpts, jwt.WithAudience(m.audience))
+		if audience != "" {
+			parserOpts = append(parserOpts, jwt.WithAudience(audience))
 		}
 
-		token, err := jwt.Parse(tokenString, m.keyFunc, parserOpts...)
+		// Verification is traced so time spent fetching keys shows up.
+		verifyCtx, span := tracing.Start(r.Context(), "jwt.verify")
+		token, err := jwt.Parse(tokenString, func(t *jwt.Token) (any, error) {
+			return m.keyFunc(verifyCtx, t)
+		}, parserOpts...)
+		tracing.RecordError(span, err)
+		span.End()
 		if err != nil || !token.Valid {
 			writeJSONError(w, http.StatusUnauthorized, "invalid token")
 			return
@@ -110,138 +254,29 @@ func (m *JWTMiddleware) Handler(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), ClaimsKey, claims)
+		ctx := withClaims(r.Context(), claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func writeJSONError(w http.ResponseWriter, status int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(map[string]string{
-		"_type":  "error",
-		"_error": message,
-	})
-}
-
-// jwksFetcher fetches and caches JWKS keys from a remote URL.
-type jwksFetcher struct {
-	url  string
-	mu   sync.RWMutex
-	keys map[string]*rsa.PublicKey
-	last time.Time
-	ttl  time.Duration
-}
-
-func newJWKSFetcher(url string) *jwksFetcher {
-	return &jwksFetcher{
-		url:  url,
-		keys: make(map[string]*rsa.PublicKey),
-		ttl:  5 * time.Minute,
+// withClaims returns ctx carrying the caller's claims, and records their
+// subject for the access log.
+func withClaims(ctx context.Context, claims jwt.MapClaims) context.Context {
+	if sub, ok := claims["sub"].(string); ok {
+		logging.SetSubject(ctx, sub)
 	}
+	return context.WithValue(ctx, ClaimsKey, claims)
 }
 
-func (f *jwksFetcher) keyFunc(token *jwt.Token) (any, error) {
-	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-	}
-
-	kid, _ := token.Header["kid"].(string)
-
-	f.mu.RLock()
-	if time.Since(f.last) < f.ttl {
-		if key, ok := f.keys[kid]; ok {
-			f.mu.RUnlock()
-			return key, nil
-		}
-	}
-	f.mu.RUnlock()
-
-	// Refresh keys
-	if err := f.refresh(); err != nil {
-		return nil, fmt.Errorf("fetching JWKS: %w", err)
-	}
-
-	f.mu.RLock()
-	defer f.mu.RUnlock()
-	key, ok := f.keys[kid]
-	if !ok {
-		return nil, fmt.Errorf("key %q not found in JWKS", kid)
-	}
-	return key, nil
-}
-
-type jwksResponse struct {
-	Keys []jwkKey `json:"keys"`
-}
-
-type jwkKey struct {
-	Kty string `json:"kty"`
-	Kid string `json:"kid"`
-	Use string `json:"use"`
-	Alg string `json:"alg"`
-	N   string `json:"n"`
-	E   string `json:"e"`
-}
-
-func (f *jwksFetcher) refresh() error {
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(f.url)
-	if err != nil {
-		return fmt.Errorf("fetching JWKS URL: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("reading JWKS response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("JWKS URL returned status %d", resp.StatusCode)
-	}
-
-	var jwks jwksResponse
-	if err := json.Unmarshal(body, &jwks); err != nil {
-		return fmt.Errorf("parsing JWKS response: %w", err)
-	}
-
-	keys := make(map[string]*rsa.PublicKey)
-	for _, k := range jwks.Keys {
-		if k.Kty != "RSA" {
-			continue
-		}
-		pub, err := parseRSAPublicKey(k)
-		if err != nil {
-			continue
-		}
-		keys[k.Kid] = pub
-	}
-
-	f.mu.Lock()
-	f.keys = keys
-	f.last = time.Now()
-	f.mu.Unlock()
-
-	return nil
-}
-
-func parseRSAPublicKey(k jwkKey) (*rsa.PublicKey, error) {
-	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
-	if err != nil {
-		return nil, fmt.Errorf("decoding modulus: %w", err)
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	body := map[string]string{
+		"_type":  "error",
+		"_error": message,
 	}
-
-	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
-	if err != nil {
-		return nil, fmt.Errorf("decoding exponent: %w", err)
+	if id := w.Header().Get(logging.RequestIDHeader); id != "" {
+		body["_requestId"] = id
 	}
-
-	n := new(big.Int).SetBytes(nBytes)
-	e := new(big.Int).SetBytes(eBytes)
-
-	return &rsa.PublicKey{
-		N: n,
-		E: int(e.Int64()),
-	}, nil
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
 }
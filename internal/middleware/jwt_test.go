@@ -318,6 +318,34 @@ func TestAudienceValidation(t *testing.T) {
 	})
 }
 
+func TestSetExpectedClaims(t *testing.T) {
+	key := generateTestKey(t)
+	m := NewJWTMiddlewareWithKey(&key.PublicKey, "https://auth.example.com", "my-api")
+	handler := m.Handler(okHandler)
+
+	tokenStr := createSignedToken(t, key, jwt.MapClaims{
+		"sub": "user123",
+		"iss": "https://auth2.example.com",
+		"aud": "other-api",
+		"exp": jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	status := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenStr)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if got := status(); got != http.StatusUnauthorized {
+		t.Fatalf("expected 401 before the change, got %d", got)
+	}
+	m.SetExpectedClaims("https://auth2.example.com", "other-api")
+	if got := status(); got != http.StatusOK {
+		t.Fatalf("expected 200 after the change, got %d", got)
+	}
+}
+
 func TestWrongSigningKey_Returns401(t *testing.T) {
 	signingKey := generateTestKey(t)
 	verifyKey := generateTestKey(t)
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/ratelimit"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// globalRateLimitKey is the single bucket shared by all clients.
+const globalRateLimitKey = "*"
+
+// RateLimitMiddleware applies the server-wide rate limits. It must run after
+// authentication, since clients are identified by their sub claim.
+type RateLimitMiddleware struct {
+	global    *ratelimit.Limiter
+	perClient *ratelimit.Limiter
+}
+
+// NewRateLimitMiddleware creates a RateLimitMiddleware. Either limiter may
+// be nil.
+func NewRateLimitMiddleware(global, perClient *ratelimit.Limiter) *RateLimitMiddleware {
+	return &RateLimitMiddleware{global: global, perClient: perClient}
+}
+
+// Handler returns an http.Handler that rejects requests over the client's or
+// the global limit with 429. The client's limit is checked first, so a
+// client that is over its own limit does not use up the global one.
+func (m *RateLimitMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := r.Context().Value(ClaimsKey).(jwt.MapClaims)
+		subject, _ := claims["sub"].(string)
+
+		if res, ok := m.perClient.Allow(ratelimit.ClientKey(r, subject)); ok {
+			ratelimit.WriteHeaders(w.Header(), res)
+			if !res.Allowed {
+				writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+		}
+		if res, ok := m.global.Allow(globalRateLimitKey); ok {
+			ratelimit.WriteHeaders(w.Header(), res)
+			if !res.Allowed {
+				writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/ratelimit"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	m := NewRateLimitMiddleware(
+		ratelimit.New(&ratelimit.Limit{Rate: 0.001, Burst: 3}, nil),
+		ratelimit.New(&ratelimit.Limit{Rate: 0.001, Burst: 1}, nil),
+	)
+	handler := m.Handler(okHandler)
+
+	serve := func(sub string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), ClaimsKey, jwt.MapClaims{"sub": sub}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("alice"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("expected 200 with no requests remaining, got %d %v", rec.Code, rec.Header())
+	}
+	rec := serve("alice")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After over the client limit, got %d %v", rec.Code, rec.Header())
+	}
+
+	if rec := serve("bob"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for another client, got %d", rec.Code)
+	}
+	// The rejected request did not use up the global limit.
+	if rec := serve("carol"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 within the global limit, got %d", rec.Code)
+	}
+	if rec := serve("dave"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 over the global limit, got %d", rec.Code)
+	}
+}
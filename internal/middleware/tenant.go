@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/database"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TenantMiddleware routes each request to the PostgreSQL schema of the tenant
+// named by a JWT claim. It must run after JWTMiddleware. Tenant schemas are
+// provisioned by migrate; the middleware never creates them.
+type TenantMiddleware struct {
+	claim     string
+	schemaFor func(tenant string) (string, error)
+	check     func(ctx context.Context, schema string) error
+
+	checkMu sync.Mutex // serializes check calls
+	mu      sync.Mutex
+	ready   map[string]bool // only schemas that exist, so bounded by those provisioned
+}
+
+// NewTenantMiddleware creates a TenantMiddleware. schemaFor maps a tenant claim
+// value to its schema name. check is called for a schema until it succeeds,
+// to confirm the schema is provisioned and its tables match the config; it
+// returns an error wrapping database.ErrSchemaNotFound for schemas that do
+// not exist.
+func NewTenantMiddleware(claim string, schemaFor func(tenant string) (string, error), check func(ctx context.Context, schema string) error) *TenantMiddleware {
+	return &TenantMiddleware{
+		claim:     claim,
+		schemaFor: schemaFor,
+		check:     check,
+		ready:     make(map[string]bool),
+	}
+}
+
+// Handler returns an http.Handler that sets the tenant schema on the request context.
+func (m *TenantMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := r.Context().Value(ClaimsKey).(jwt.MapClaims)
+		tenant, ok := claims[m.claim].(string)
+		if !ok || tenant == "" {
+			writeJSONError(w, http.StatusForbidden, "missing tenant claim "+m.claim)
+			return
+		}
+
+		schema, err := m.schemaFor(tenant)
+		if err != nil {
+			writeJSONError(w, http.StatusForbidden, "invalid tenant claim "+m.claim)
+			return
+		}
+
+		if err := m.ensureReady(r.Context(), schema); err != nil {
+			if errors.Is(err, database.ErrSchemaNotFound) {
+				writeJSONError(w, http.StatusForbidden, "tenant is not provisioned")
+				return
+			}
+			slog.ErrorContext(r.Context(), "tenant schema is not ready", "schema", schema, "error", err)
+			writeJSONError(w, http.StatusServiceUnavailable, "tenant schema is not ready")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(database.WithSchema(r.Context(), schema)))
+	})
+}
+
+// ensureReady runs check for a schema that has not passed it yet.
+// Concurrent first requests for the same tenant are serialized.
+func (m *TenantMiddleware) ensureReady(ctx context.Context, schema string) error {
+	if m.isReady(schema) {
+		return nil
+	}
+
+	m.checkMu.Lock()
+	defer m.checkMu.Unlock()
+
+	if m.isReady(schema) {
+		return nil
+	}
+	if err := m.check(ctx, schema); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.ready[schema] = true
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *TenantMiddleware) isReady(schema string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ready[schema]
+}
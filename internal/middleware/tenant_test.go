@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/database"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func tenantRequest(claims jwt.MapClaims) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	return req.WithContext(context.WithValue(req.Context(), ClaimsKey, claims))
+}
+
+func testSchemaFor(tenant string) (string, error) {
+	if tenant == "bad;tenant" {
+		return "", errors.New("invalid tenant")
+	}
+	return "app_" + tenant, nil
+}
+
+func TestTenantMiddleware_ChecksSchemaOnce(t *testing.T) {
+	var checked []string
+	m := NewTenantMiddleware("org", testSchemaFor, func(ctx context.Context, schema string) error {
+		checked = append(checked, schema)
+		return nil
+	})
+	handler := m.Handler(okHandler)
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, tenantRequest(jwt.MapClaims{"org": "acme"}))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	}
+
+	if len(checked) != 1 || checked[0] != "app_acme" {
+		t.Fatalf("expected app_acme to be checked once, got %v", checked)
+	}
+}
+
+func TestTenantMiddleware_RejectsMissingOrInvalidClaim(t *testing.T) {
+	m := NewTenantMiddleware("org", testSchemaFor, func(ctx context.Context, schema string) error {
+		t.Fatalf("check should not be called, got %q", schema)
+		return nil
+	})
+	handler := m.Handler(okHandler)
+
+	for _, claims := range []jwt.MapClaims{{}, {"org": 42}, {"org": "bad;tenant"}} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, tenantRequest(claims))
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("claims %v: expected 403, got %d", claims, rec.Code)
+		}
+	}
+}
+
+func TestTenantMiddleware_UnreadySchemaIsRetried(t *testing.T) {
+	calls := 0
+	m := NewTenantMiddleware("org", testSchemaFor, func(ctx context.Context, schema string) error {
+		calls++
+		if calls == 1 {
+			return errors.New("config hash is missing")
+		}
+		return nil
+	})
+	handler := m.Handler(okHandler)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, tenantRequest(jwt.MapClaims{"org": "acme"}))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, tenantRequest(jwt.MapClaims{"org": "acme"}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 after the schema was migrated, got %d", rec.Code)
+	}
+}
+
+func TestTenantMiddleware_RejectsUnprovisionedTenant(t *testing.T) {
+	calls := 0
+	m := NewTenantMiddleware("org", testSchemaFor, func(ctx context.Context, schema string) error {
+		calls++
+		return fmt.Errorf("schema %q: %w", schema, database.ErrSchemaNotFound)
+	})
+	handler := m.Handler(okHandler)
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, tenantRequest(jwt.MapClaims{"org": "unknown"}))
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d", rec.Code)
+		}
+	}
+	if calls != 2 || len(m.ready) != 0 {
+		t.Fatalf("expected unprovisioned schemas to be checked each time and not cached, got %d calls and %v", calls, m.ready)
+	}
+}
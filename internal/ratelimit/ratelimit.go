@@ -0,0 +1,185 @@
+// Package ratelimit implements token-bucket rate limits keyed by client.
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+)
+
+// sweepInterval is how often buckets that have refilled completely are
+// dropped, so idle clients do not keep memory.
+const sweepInterval = time.Minute
+
+// Limit is a token bucket: Burst requests at once, refilled at Rate requests
+// per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result describes a bucket after a request was counted against it.
+type Result struct {
+	Allowed    bool
+	Limit      int           // bucket size
+	Remaining  int           // whole requests left in the bucket
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request is allowed, when not Allowed
+}
+
+// Limiter holds one bucket per key. Keys listed in the overrides get their
+// own limit; other keys get the default limit, or are not limited when there
+// is none.
+type Limiter struct {
+	limit     *Limit
+	overrides map[string]Limit
+	now       func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// New creates a Limiter. limit may be nil when only the overrides are
+// limited.
+func New(limit *Limit, overrides map[string]Limit) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		overrides: overrides,
+		now:       time.Now,
+		buckets:   make(map[string]*bucket),
+	}
+}
+
+// FromConfig creates a Limiter from a configured default limit and per-key
+// overrides, or returns nil when neither is set.
+func FromConfig(limit *config.RateLimitConfig, overrides map[string]*config.RateLimitConfig) *Limiter {
+	if limit == nil && len(overrides) == 0 {
+		return nil
+	}
+	var def *Limit
+	if limit != nil {
+		l := fromConfig(limit)
+		def = &l
+	}
+	byKey := make(map[string]Limit, len(overrides))
+	for key, o := range overrides {
+		byKey[key] = fromConfig(o)
+	}
+	return New(def, byKey)
+}
+
+func fromConfig(c *config.RateLimitConfig) Limit {
+	return Limit{Rate: c.RequestsPerSecond, Burst: c.Burst}
+}
+
+// Allow counts a request for key. ok is false when the key is not limited,
+// in which case the Result is empty. A nil Limiter limits nothing.
+func (l *Limiter) Allow(key string) (res Result, ok bool) {
+	if l == nil {
+		return Result{}, false
+	}
+	limit, ok := l.overrides[key]
+	if !ok {
+		if l.limit == nil {
+			return Result{}, false
+		}
+		limit = *l.limit
+	}
+
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{limit: limit, tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+
+	res = Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+	return res, true
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+		b.last = now
+	}
+}
+
+// sweep drops buckets that have refilled completely; they are recreated full
+// on the key's next request. The caller must hold mu.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ClientKey identifies the client a request is limited as: its subject, or
+// its remote address when it has none.
+func ClientKey(r *http.Request, subject string) string {
+	if subject != "" {
+		return subject
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// WriteHeaders sets the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers, and Retry-After when the request was not allowed.
+// When several limits apply to one request, the headers describe the one
+// with the fewest requests remaining.
+func WriteHeaders(h http.Header, res Result) {
+	if prev := h.Get("RateLimit-Remaining"); prev != "" && res.Allowed {
+		if n, err := strconv.Atoi(prev); err == nil && n <= res.Remaining {
+			return
+		}
+	}
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(res.RetryAfter), 1)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
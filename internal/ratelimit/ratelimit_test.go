@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(limit *Limit, overrides map[string]Limit) (*Limiter, *time.Time) {
+	l := New(limit, overrides)
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiter_BurstThenRefill(t *testing.T) {
+	l, now := newTestLimiter(&Limit{Rate: 2, Burst: 3}, nil)
+
+	for i := range 3 {
+		res, ok := l.Allow("alice")
+		if !ok || !res.Allowed {
+			t.Fatalf("request %d: expected allowed, got %+v", i, res)
+		}
+		if res.Remaining != 2-i {
+			t.Fatalf("request %d: expected %d remaining, got %d", i, 2-i, res.Remaining)
+		}
+	}
+
+	res, _ := l.Allow("alice")
+	if res.Allowed {
+		t.Fatal("expected the fourth request to be rejected")
+	}
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("expected retry after 500ms, got %v", res.RetryAfter)
+	}
+
+	if res, _ := l.Allow("bob"); !res.Allowed {
+		t.Fatal("expected another client to have its own bucket")
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if res, _ := l.Allow("alice"); !res.Allowed {
+		t.Fatal("expected a token after refill")
+	}
+}
+
+func TestLimiter_Overrides(t *testing.T) {
+	l, _ := newTestLimiter(nil, map[string]Limit{"batch": {Rate: 1, Burst: 1}})
+
+	if _, ok := l.Allow("alice"); ok {
+		t.Fatal("expected keys without a limit not to be limited")
+	}
+	if res, ok := l.Allow("batch"); !ok || !res.Allowed {
+		t.Fatalf("expected first batch request to be allowed, got %+v", res)
+	}
+	if res, _ := l.Allow("batch"); res.Allowed {
+		t.Fatal("expected second batch request to be rejected")
+	}
+
+	var nilLimiter *Limiter
+	if _, ok := nilLimiter.Allow("alice"); ok {
+		t.Fatal("expected a nil limiter not to limit")
+	}
+}
+
+func TestLimiter_SweepDropsFullBuckets(t *testing.T) {
+	l, now := newTestLimiter(&Limit{Rate: 1, Burst: 1}, nil)
+	l.Allow("alice")
+
+	*now = now.Add(2 * sweepInterval)
+	l.Allow("bob")
+	if _, ok := l.buckets["alice"]; ok {
+		t.Fatal("expected the idle bucket to be swept")
+	}
+}
+
+func TestWriteHeaders_KeepsTightestLimit(t *testing.T) {
+	h := http.Header{}
+	WriteHeaders(h, Result{Allowed: true, Limit: 10, Remaining: 2, Reset: 1500 * time.Millisecond})
+	WriteHeaders(h, Result{Allowed: true, Limit: 100, Remaining: 50, Reset: time.Second})
+
+	if h.Get("RateLimit-Limit") != "10" || h.Get("RateLimit-Remaining") != "2" || h.Get("RateLimit-Reset") != "2" {
+		t.Fatalf("unexpected headers: %v", h)
+	}
+
+	WriteHeaders(h, Result{Limit: 100, Remaining: 0, Reset: time.Second, RetryAfter: 200 * time.Millisecond})
+	if h.Get("RateLimit-Limit") != "100" || h.Get("Retry-After") != "1" {
+		t.Fatalf("expected a rejection to replace the headers, got %v", h)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+
+	if got := ClientKey(r, "alice"); got != "alice" {
+		t.Fatalf("expected subject, got %q", got)
+	}
+	if got := ClientKey(r, ""); got != "ip:192.0.2.1" {
+		t.Fatalf("expected remote address, got %q", got)
+	}
+}
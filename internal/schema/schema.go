@@ -184,49 +184,66 @@ func Compile(rawSchema any) (*Validator, error) {
 	return &Validator{compiled: compiled}, nil
 }
 
+// FieldError describes a single schema violation at a JSON Pointer location
+// within the validated document.
+type FieldError struct {
+	Path    string
+	Message string
+}
+
 // Validate validates a JSON document against the compiled schema.
 func (v *Validator) Validate(doc map[string]any) error {
+	fieldErrors, err := v.FieldErrors(doc)
+	if err != nil {
+		return err
+	}
+	if fieldErrors == nil {
+		return nil
+	}
+	return formatValidationError(fieldErrors)
+}
+
+// FieldErrors validates a JSON document against the compiled schema and returns
+// every violation found. It returns nil when the document is valid.
+func (v *Validator) FieldErrors(doc map[string]any) ([]FieldError, error) {
 	docBytes, err := json.Marshal(doc)
 	if err != nil {
-		return fmt.Errorf("failed to marshal document to JSON: %w", err)
+		return nil, fmt.Errorf("failed to marshal document to JSON: %w", err)
 	}
 
 	inst, err := jsonschema.UnmarshalJSON(strings.NewReader(string(docBytes)))
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal document JSON: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal document JSON: %w", err)
 	}
 
-	if err := v.compiled.Validate(inst); err != nil {
-		return formatValidationError(err)
+	err = v.compiled.Validate(inst)
+	if err == nil {
+		return nil, nil
 	}
 
-	return nil
-}
-
-func formatValidationError(err error) error {
 	var validationErr *jsonschema.ValidationError
 	if !errors.As(err, &validationErr) {
-		return fmt.Errorf("validation failed: %v", err)
+		return nil, fmt.Errorf("validation failed: %v", err)
 	}
 
-	lines := validationErrorLines(validationErr.BasicOutput())
-	if len(lines) == 0 {
-		return fmt.Errorf("validation failed: jsonschema validation failed")
-	}
-	return fmt.Errorf("validation failed: jsonschema validation failed\n%s", strings.Join(lines, "\n"))
+	fieldErrors := make([]FieldError, 0)
+	appendFieldErrors(validationErr.BasicOutput(), &fieldErrors)
+	return fieldErrors, nil
 }
 
-func validationErrorLines(output *jsonschema.OutputUnit) []string {
-	if output == nil {
-		return nil
+func formatValidationError(fieldErrors []FieldError) error {
+	if len(fieldErrors) == 0 {
+		return fmt.Errorf("validation failed: jsonschema validation failed")
 	}
 
-	lines := make([]string, 0, len(output.Errors))
-	appendValidationErrorLines(output, &lines)
-	return lines
+	lines := make([]string, len(fieldErrors))
+	for i, fe := range fieldErrors {
+		lines[i] = fmt.Sprintf("- at '%s': %s", fe.Path, fe.Message)
+	}
+	return fmt.Errorf("validation failed: jsonschema validation failed\n%s", strings.Join(lines, "\n"))
 }
 
-func appendValidationErrorLines(output *jsonschema.OutputUnit, lines *[]string) {
+func appendFieldErrors(output *jsonschema.OutputUnit, fieldErrors *[]FieldError) {
 	if output == nil {
 		return
 	}
@@ -235,9 +252,9 @@ func appendValidationErrorLines(output *jsonschema.OutputUnit, lines *[]string)
 		if location == "" {
 			location = "/"
 		}
-		*lines = append(*lines, fmt.Sprintf("- at '%s': %s", location, output.Error.String()))
+		*fieldErrors = append(*fieldErrors, FieldError{Path: location, Message: output.Error.String()})
 	}
 	for i := range output.Errors {
-		appendValidationErrorLines(&output.Errors[i], lines)
+		appendFieldErrors(&output.Errors[i], fieldErrors)
 	}
 }
@@ -180,3 +180,44 @@ func TestValidateErrorDoesNotLeakSchemaPath(t *testing.T) {
 		t.Fatalf("expected instance location in error message, got: %s", msg)
 	}
 }
+
+func TestFieldErrorsReportsEveryViolation(t *testing.T) {
+	v, err := Compile(validSchema())
+	if err != nil {
+		t.Fatalf("failed to compile schema: %v", err)
+	}
+
+	fieldErrors, err := v.FieldErrors(map[string]any{
+		"name": 42,
+		"age":  "thirty",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	paths := make(map[string]bool)
+	for _, fe := range fieldErrors {
+		paths[fe.Path] = true
+		if fe.Message == "" {
+			t.Fatalf("expected message for path %q", fe.Path)
+		}
+	}
+	if !paths["/name"] || !paths["/age"] {
+		t.Fatalf("expected violations at /name and /age, got %#v", fieldErrors)
+	}
+}
+
+func TestFieldErrorsValidDocument(t *testing.T) {
+	v, err := Compile(validSchema())
+	if err != nil {
+		t.Fatalf("failed to compile schema: %v", err)
+	}
+
+	fieldErrors, err := v.FieldErrors(map[string]any{"name": "Alice", "age": 30})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fieldErrors != nil {
+		t.Fatalf("expected no violations, got %#v", fieldErrors)
+	}
+}
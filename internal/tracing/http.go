@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span for every request served by next,
+// continuing the caller's trace when the request has a traceparent header.
+// Spans are named after the mux route the request matches, whether or not it
+// reaches it. Responses with a 5xx status mark the span as failed.
+func Middleware(mux logging.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.Method
+		attrs := []attribute.KeyValue{
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		}
+		if _, pattern := mux.Handler(r); pattern != "" {
+			if _, path, ok := strings.Cut(pattern, " "); ok {
+				pattern = path
+			}
+			name += " " + pattern
+			attrs = append(attrs, attribute.String("http.route", pattern))
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		sw := &logging.StatusWriter{ResponseWriter: w, Status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", sw.Status))
+		if sw.Status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.Status))
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Setup installs a tracer provider exporting to the configured exporter and
+// W3C trace context propagation. It returns a nil shutdown when tracing is
+// not configured; otherwise shutdown exports the spans already ended and
+// closes the exporter.
+func Setup(ctx context.Context, c config.TracingConfig) (shutdown func(context.Context) error, err error) {
+	if c.Exporter == "" {
+		return nil, nil
+	}
+	exp, err := newExporter(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", c.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("building trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler(c)),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Error("tracing failed", "error", err)
+	}))
+	return tp.Shutdown, nil
+}
+
+// sampler records the configured share of new traces. Requests with a
+// traceparent follow the caller's sampling decision.
+func sampler(c config.TracingConfig) sdktrace.Sampler {
+	ratio := 1.0
+	if c.SampleRatio != nil {
+		ratio = *c.SampleRatio
+	}
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+}
+
+func newExporter(ctx context.Context, c config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch c.Exporter {
+	case "otlp":
+		exp, err := otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpointURL(strings.TrimSuffix(c.Endpoint, "/")+"/v1/traces"),
+			otlptracehttp.WithHeaders(c.Headers),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+		}
+		return exp, nil
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		f, err := os.OpenFile(c.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("opening trace file: %w", err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &fileExporter{SpanExporter: exp, f: f}, nil
+	}
+	return nil, fmt.Errorf("unknown trace exporter %q", c.Exporter)
+}
+
+// fileExporter closes the trace file when the exporter shuts down.
+type fileExporter struct {
+	sdktrace.SpanExporter
+	f *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.f.Close())
+}
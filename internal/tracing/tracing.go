@@ -0,0 +1,44 @@
+// Package tracing records OpenTelemetry spans, propagates W3C trace context,
+// and exports finished spans over OTLP/HTTP or as JSON lines.
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer the service's spans are recorded
+// with.
+const instrumentationName = "github.com/UnitVectorY-Labs/itemservicecentral"
+
+// Tracer returns the tracer spans are recorded with. Until Setup installs a
+// provider it records nothing.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start begins an internal span that is a child of the span in ctx, or the
+// root of a new trace. The returned context carries the span.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError marks span as failed with err. A nil err is ignored.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Inject sets the traceparent header to the span in ctx, if there is one.
+func Inject(ctx context.Context, h http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(h))
+}
@@ -0,0 +1,173 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const callerTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// useRecorder installs a tracer provider sampling ratio of new traces for
+// the test and returns the recorder its ended spans go to.
+func useRecorder(t *testing.T, ratio float64) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(rec),
+		sdktrace.WithSampler(sampler(config.TracingConfig{SampleRatio: &ratio})),
+	))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	rec := useRecorder(t, 0)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/widgets/data/{pk}/_item", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "inner")
+		span.End()
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	handler := Middleware(mux, mux)
+
+	// Without a traceparent, the ratio of 0 records nothing.
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/widgets/data/abc/_item", nil))
+	if n := len(rec.Ended()); n != 0 {
+		t.Fatalf("expected no spans, got %d", n)
+	}
+
+	// The caller's sampling decision wins over the ratio.
+	req := httptest.NewRequest(http.MethodGet, "/v1/widgets/data/abc/_item", nil)
+	req.Header.Set("traceparent", callerTraceparent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	server := spans[1]
+	if server.Name() != "GET /v1/widgets/data/{pk}/_item" || server.SpanKind() != trace.SpanKindServer {
+		t.Errorf("unexpected server span %q (%v)", server.Name(), server.SpanKind())
+	}
+	if server.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Error("server span should continue the caller's trace")
+	}
+	if server.Status().Code != codes.Error {
+		t.Error("5xx responses should mark the span as failed")
+	}
+	if spans[0].Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("handler spans should be children of the server span")
+	}
+}
+
+func TestInject(t *testing.T) {
+	useRecorder(t, 1)
+
+	ctx, span := Start(context.Background(), "outgoing")
+	defer span.End()
+	h := http.Header{}
+	Inject(ctx, h)
+
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if got := h.Get("traceparent"); got != want {
+		t.Fatalf("expected traceparent %q, got %q", want, got)
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	rec := useRecorder(t, 1)
+
+	_, ok := Start(context.Background(), "ok")
+	RecordError(ok, nil)
+	ok.End()
+	_, failed := Start(context.Background(), "failed")
+	RecordError(failed, errors.New("boom"))
+	failed.End()
+
+	spans := rec.Ended()
+	if spans[0].Status().Code != codes.Unset {
+		t.Errorf("a nil error should leave the status unset, got %v", spans[0].Status())
+	}
+	if spans[1].Status().Code != codes.Error || spans[1].Status().Description != "boom" {
+		t.Errorf("expected an error status, got %v", spans[1].Status())
+	}
+}
+
+func TestSetup_File(t *testing.T) {
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+
+	shutdown, err := Setup(context.Background(), config.TracingConfig{Exporter: "file", File: path, ServiceName: "svc"})
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	_, span := Start(context.Background(), "db GetItem")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading trace file: %v", err)
+	}
+	if !strings.Contains(string(b), `"Name":"db GetItem"`) || !strings.Contains(string(b), `"svc"`) {
+		t.Fatalf("expected the span and service name in the trace file, got %s", b)
+	}
+}
+
+func TestSetup_Disabled(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TracingConfig{})
+	if err != nil || shutdown != nil {
+		t.Fatalf("expected tracing to stay off, got %v", err)
+	}
+}
+
+func TestSetup_OTLP(t *testing.T) {
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	var path, auth, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth, contentType = r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+
+	shutdown, err := Setup(context.Background(), config.TracingConfig{
+		Exporter:    "otlp",
+		Endpoint:    srv.URL + "/",
+		Headers:     map[string]string{"Authorization": "Bearer t"},
+		ServiceName: "svc",
+	})
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	_, span := Start(context.Background(), "db GetItem")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if path != "/v1/traces" || auth != "Bearer t" || contentType != "application/x-protobuf" {
+		t.Fatalf("unexpected export to %q with Authorization %q and Content-Type %q", path, auth, contentType)
+	}
+}
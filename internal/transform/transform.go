@@ -0,0 +1,262 @@
+package transform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	fileNameRegexp = regexp.MustCompile(`^([0-9]+)_([a-z0-9_]+)\.ya?ml$`)
+	segmentRegexp  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+)
+
+// Migration is a versioned list of data transformation steps for one table,
+// loaded from a single file in the migrations directory.
+type Migration struct {
+	Version  int    `yaml:"-"`
+	Name     string `yaml:"-"` // file name without extension, for example 0001_rename_full_name
+	Checksum string `yaml:"-"` // SHA-256 of the file contents
+	Table    string `yaml:"table"`
+	Steps    []Step `yaml:"steps"`
+}
+
+// Step is a single transformation. Exactly one operation must be set.
+type Step struct {
+	Rename     *FieldPair    `yaml:"rename"`
+	Move       *FieldPair    `yaml:"move"`
+	Copy       *FieldPair    `yaml:"copy"`
+	SetDefault *DefaultValue `yaml:"setDefault"`
+	Delete     *Field        `yaml:"delete"`
+}
+
+// FieldPair names a source and destination attribute path.
+type FieldPair struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// DefaultValue sets an attribute path to a value on items where it is absent.
+type DefaultValue struct {
+	Field string `yaml:"field"`
+	Value any    `yaml:"value"`
+}
+
+// Field names a single attribute path.
+type Field struct {
+	Field string `yaml:"field"`
+}
+
+// Load reads every migration file from dir and returns them ordered by version.
+// File names must look like 0001_description.yaml.
+func Load(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations directory: %w", err)
+	}
+
+	var migrations []Migration
+	versions := make(map[int]string)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		m := fileNameRegexp.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration file %q must be named like 0001_description.yaml", e.Name())
+		}
+		version, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("migration file %q: invalid version: %w", e.Name(), err)
+		}
+		if other, ok := versions[version]; ok {
+			return nil, fmt.Errorf("migration files %q and %q share version %d", other, e.Name(), version)
+		}
+		versions[version] = e.Name()
+
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading migration file %q: %w", e.Name(), err)
+		}
+
+		var mig Migration
+		if err := yaml.Unmarshal(data, &mig); err != nil {
+			return nil, fmt.Errorf("parsing migration file %q: %w", e.Name(), err)
+		}
+		sum := sha256.Sum256(data)
+		mig.Version = version
+		mig.Name = strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		mig.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Validate checks that each migration targets a configured table and that its
+// steps are well formed. Key fields are stored outside the data column, so they
+// may only be used as the source of a copy.
+func Validate(migrations []Migration, tables []config.TableConfig) error {
+	byName := make(map[string]config.TableConfig, len(tables))
+	for _, t := range tables {
+		byName[t.Name] = t
+	}
+
+	for _, m := range migrations {
+		t, ok := byName[m.Table]
+		if !ok {
+			return fmt.Errorf("migration %q: table %q is not configured", m.Name, m.Table)
+		}
+		if len(m.Steps) == 0 {
+			return fmt.Errorf("migration %q: at least one step is required", m.Name)
+		}
+		for i, step := range m.Steps {
+			if err := validateStep(step, t); err != nil {
+				return fmt.Errorf("migration %q: step[%d]: %w", m.Name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Op returns the name of the operation set on the step.
+func (s Step) Op() string {
+	ops := s.ops()
+	if len(ops) != 1 {
+		return ""
+	}
+	return ops[0]
+}
+
+func (s Step) ops() []string {
+	var ops []string
+	if s.Rename != nil {
+		ops = append(ops, "rename")
+	}
+	if s.Move != nil {
+		ops = append(ops, "move")
+	}
+	if s.Copy != nil {
+		ops = append(ops, "copy")
+	}
+	if s.SetDefault != nil {
+		ops = append(ops, "setDefault")
+	}
+	if s.Delete != nil {
+		ops = append(ops, "delete")
+	}
+	return ops
+}
+
+// String describes the step for logs and dry-run output.
+func (s Step) String() string {
+	switch s.Op() {
+	case "rename":
+		return fmt.Sprintf("rename %s to %s", s.Rename.From, s.Rename.To)
+	case "move":
+		return fmt.Sprintf("move %s to %s", s.Move.From, s.Move.To)
+	case "copy":
+		return fmt.Sprintf("copy %s to %s", s.Copy.From, s.Copy.To)
+	case "setDefault":
+		return fmt.Sprintf("set default %s", s.SetDefault.Field)
+	case "delete":
+		return fmt.Sprintf("delete %s", s.Delete.Field)
+	default:
+		return "invalid step"
+	}
+}
+
+func validateStep(s Step, t config.TableConfig) error {
+	ops := s.ops()
+	if len(ops) != 1 {
+		return fmt.Errorf("exactly one of rename, move, copy, setDefault, or delete must be set")
+	}
+
+	switch ops[0] {
+	case "rename":
+		if err := validatePair(s.Rename, t, false); err != nil {
+			return fmt.Errorf("rename: %w", err)
+		}
+		from, to := SplitPath(s.Rename.From), SplitPath(s.Rename.To)
+		if strings.Join(from[:len(from)-1], ".") != strings.Join(to[:len(to)-1], ".") {
+			return fmt.Errorf("rename: from and to must share the same parent; use move instead")
+		}
+	case "move":
+		if err := validatePair(s.Move, t, false); err != nil {
+			return fmt.Errorf("move: %w", err)
+		}
+	case "copy":
+		if err := validatePair(s.Copy, t, true); err != nil {
+			return fmt.Errorf("copy: %w", err)
+		}
+	case "setDefault":
+		if err := validatePath(s.SetDefault.Field, t, false); err != nil {
+			return fmt.Errorf("setDefault: field: %w", err)
+		}
+		if s.SetDefault.Value == nil {
+			return fmt.Errorf("setDefault: value is required")
+		}
+	case "delete":
+		if err := validatePath(s.Delete.Field, t, false); err != nil {
+			return fmt.Errorf("delete: field: %w", err)
+		}
+	}
+	return nil
+}
+
+func validatePair(p *FieldPair, t config.TableConfig, allowKeySource bool) error {
+	if err := validatePath(p.From, t, allowKeySource); err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	if err := validatePath(p.To, t, false); err != nil {
+		return fmt.Errorf("to: %w", err)
+	}
+	if p.From == p.To {
+		return fmt.Errorf("from and to must be different")
+	}
+	if strings.HasPrefix(p.To+".", p.From+".") || strings.HasPrefix(p.From+".", p.To+".") {
+		return fmt.Errorf("from and to must not contain each other")
+	}
+	return nil
+}
+
+func validatePath(path string, t config.TableConfig, allowKey bool) error {
+	if path == "" {
+		return fmt.Errorf("is required")
+	}
+	segments := SplitPath(path)
+	for _, seg := range segments {
+		if !segmentRegexp.MatchString(seg) {
+			return fmt.Errorf("path %q segment %q must match %s", path, seg, segmentRegexp.String())
+		}
+	}
+	if len(segments) == 1 && IsKeyField(t, path) && !allowKey {
+		return fmt.Errorf("%q is a key field of table %q and cannot be modified", path, t.Name)
+	}
+	return nil
+}
+
+// SplitPath splits a dot-separated attribute path into its segments.
+func SplitPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// IsKeyField reports whether a top-level attribute is the table's primary or range key field.
+func IsKeyField(t config.TableConfig, field string) bool {
+	if field == t.PrimaryKey.Field {
+		return true
+	}
+	return t.RangeKey != nil && field == t.RangeKey.Field
+}
@@ -0,0 +1,128 @@
+package transform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+)
+
+func writeMigration(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("writing migration file: %v", err)
+	}
+}
+
+func usersTable() config.TableConfig {
+	return config.TableConfig{
+		Name:       "users",
+		PrimaryKey: config.KeyConfig{Field: "userId"},
+	}
+}
+
+func TestLoad_OrdersByVersion(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "0010_drop_legacy.yaml", `
+table: users
+steps:
+  - delete:
+      field: legacy
+`)
+	writeMigration(t, dir, "0002_rename_full_name.yml", `
+table: users
+steps:
+  - rename:
+      from: fullName
+      to: name
+  - setDefault:
+      field: status
+      value: active
+`)
+
+	migrations, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(migrations))
+	}
+	if migrations[0].Version != 2 || migrations[0].Name != "0002_rename_full_name" {
+		t.Fatalf("unexpected first migration: %+v", migrations[0])
+	}
+	if migrations[1].Version != 10 {
+		t.Fatalf("expected version 10 second, got %d", migrations[1].Version)
+	}
+	if len(migrations[0].Steps) != 2 || migrations[0].Steps[0].Op() != "rename" || migrations[0].Steps[1].Op() != "setDefault" {
+		t.Fatalf("unexpected steps: %+v", migrations[0].Steps)
+	}
+	if migrations[0].Checksum == "" || migrations[0].Checksum == migrations[1].Checksum {
+		t.Fatal("expected distinct non-empty checksums")
+	}
+}
+
+func TestLoad_RejectsBadFileName(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "rename.yaml", "table: users\n")
+
+	if _, err := Load(dir); err == nil {
+		t.Fatal("expected error for file without version prefix")
+	}
+}
+
+func TestLoad_RejectsDuplicateVersion(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "0001_a.yaml", "table: users\n")
+	writeMigration(t, dir, "1_b.yaml", "table: users\n")
+
+	_, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), "share version 1") {
+		t.Fatalf("expected duplicate version error, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tables := []config.TableConfig{usersTable()}
+
+	tests := []struct {
+		name    string
+		step    Step
+		wantErr string
+	}{
+		{"move into nested object", Step{Move: &FieldPair{From: "city", To: "address.city"}}, ""},
+		{"copy key field", Step{Copy: &FieldPair{From: "userId", To: "ownerId"}}, ""},
+		{"no operation", Step{}, "exactly one of"},
+		{"two operations", Step{Delete: &Field{Field: "a"}, Move: &FieldPair{From: "b", To: "c"}}, "exactly one of"},
+		{"rename across parents", Step{Rename: &FieldPair{From: "city", To: "address.city"}}, "use move instead"},
+		{"move key field", Step{Move: &FieldPair{From: "userId", To: "id"}}, "key field"},
+		{"copy onto key field", Step{Copy: &FieldPair{From: "email", To: "userId"}}, "key field"},
+		{"delete key field", Step{Delete: &Field{Field: "userId"}}, "key field"},
+		{"move into itself", Step{Move: &FieldPair{From: "address", To: "address.home"}}, "contain each other"},
+		{"invalid segment", Step{Delete: &Field{Field: "a..b"}}, "segment"},
+		{"default without value", Step{SetDefault: &DefaultValue{Field: "status"}}, "value is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]Migration{{Name: "0001_test", Table: "users", Steps: []Step{tt.step}}}, tables)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_UnknownTable(t *testing.T) {
+	err := Validate([]Migration{{Name: "0001_test", Table: "orders", Steps: []Step{{Delete: &Field{Field: "a"}}}}}, []config.TableConfig{usersTable()})
+	if err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Fatalf("expected unknown table error, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/database"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/model"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/schema"
+)
+
+// pageSize is the number of rows read per page while streaming a table.
+const pageSize = 500
+
+// Scanner is the subset of database.Store used to stream table rows.
+type Scanner interface {
+	ScanTable(ctx context.Context, table string, hasRK bool, opts database.ListOptions) (*database.ListResult, error)
+}
+
+// Violation describes a stored item that does not validate against its table schema.
+type Violation struct {
+	Table   string
+	PKField string
+	PK      string
+	RKField string // empty if the table has no range key
+	RK      string
+	Errors  []schema.FieldError
+}
+
+// Keys renders the item's key fields, for example orderId="o1" lineId="l1".
+func (v Violation) Keys() string {
+	keys := fmt.Sprintf("%s=%q", v.PKField, v.PK)
+	if v.RKField != "" {
+		keys += fmt.Sprintf(" %s=%q", v.RKField, v.RK)
+	}
+	return keys
+}
+
+// String renders the violation with one line per schema error.
+func (v Violation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "table %q: item %s", v.Table, v.Keys())
+	for _, fe := range v.Errors {
+		fmt.Fprintf(&b, "\n  - at '%s': %s", fe.Path, fe.Message)
+	}
+	return b.String()
+}
+
+// TableResult summarizes the verification of a single table.
+type TableResult struct {
+	Table      string
+	Missing    bool // the physical table does not exist yet
+	Checked    int
+	Violations int
+}
+
+// Tables streams every row of each table through its compiled schema and calls
+// report for each non-conforming item. Tables that do not exist yet are skipped
+// and marked as missing.
+func Tables(ctx context.Context, scanner Scanner, tables []config.TableConfig, report func(Violation)) ([]TableResult, error) {
+	results := make([]TableResult, 0, len(tables))
+	for _, t := range tables {
+		result, err := table(ctx, scanner, t, report)
+		if err != nil {
+			return nil, fmt.Errorf("table %q: %w", t.Name, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func table(ctx context.Context, scanner Scanner, t config.TableConfig, report func(Violation)) (TableResult, error) {
+	result := TableResult{Table: t.Name}
+
+	v, err := schema.Compile(t.Schema)
+	if err != nil {
+		return result, fmt.Errorf("failed to compile schema: %w", err)
+	}
+
+	hasRK := t.RangeKey != nil
+	rkField := ""
+	if hasRK {
+		rkField = t.RangeKey.Field
+	}
+
+	opts := database.ListOptions{Limit: pageSize}
+	for {
+		page, err := scanner.ScanTable(ctx, t.Name, hasRK, opts)
+		if err != nil {
+			if database.IsUndefinedTableError(err) {
+				result.Missing = true
+				return result, nil
+			}
+			return result, err
+		}
+
+		for _, item := range page.Items {
+			result.Checked++
+
+			doc := model.InjectKeys(item.Data, t.PrimaryKey.Field, item.PK, rkField, item.RK)
+			fieldErrors, err := v.FieldErrors(doc)
+			if err != nil {
+				return result, fmt.Errorf("validating item %q: %w", item.PK, err)
+			}
+			if fieldErrors == nil {
+				continue
+			}
+
+			result.Violations++
+			report(Violation{
+				Table:   t.Name,
+				PKField: t.PrimaryKey.Field,
+				PK:      item.PK,
+				RKField: rkField,
+				RK:      item.RK,
+				Errors:  fieldErrors,
+			})
+		}
+
+		if page.NextPageToken == "" {
+			return result, nil
+		}
+		opts.PageToken = page.NextPageToken
+	}
+}
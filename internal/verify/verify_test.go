@@ -0,0 +1,111 @@
+package verify
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/database"
+	"github.com/lib/pq"
+)
+
+// fakeScanner serves pages of items keyed by table name.
+type fakeScanner struct {
+	pages map[string][]*database.ListResult
+	calls map[string]int
+}
+
+func (f *fakeScanner) ScanTable(_ context.Context, table string, _ bool, _ database.ListOptions) (*database.ListResult, error) {
+	pages, ok := f.pages[table]
+	if !ok {
+		return nil, &pq.Error{Code: "42P01"}
+	}
+	i := f.calls[table]
+	f.calls[table]++
+	return pages[i], nil
+}
+
+func ordersTable() config.TableConfig {
+	return config.TableConfig{
+		Name: "orders",
+		PrimaryKey: config.KeyConfig{
+			Field:   "orderId",
+			Pattern: "^[A-Za-z0-9]+$",
+		},
+		RangeKey: &config.KeyConfig{
+			Field:   "lineId",
+			Pattern: "^[A-Za-z0-9]+$",
+		},
+		Schema: map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"orderId": map[string]any{"type": "string", "pattern": "^[A-Za-z0-9]+$"},
+				"lineId":  map[string]any{"type": "string", "pattern": "^[A-Za-z0-9]+$"},
+				"amount":  map[string]any{"type": "number"},
+			},
+			"required": []any{"orderId", "lineId", "amount"},
+		},
+	}
+}
+
+func TestTablesReportsViolationsAcrossPages(t *testing.T) {
+	scanner := &fakeScanner{
+		calls: map[string]int{},
+		pages: map[string][]*database.ListResult{
+			"orders": {
+				{
+					Items: []database.ItemResult{
+						{PK: "o1", RK: "l1", Data: map[string]any{"amount": 10.0}},
+						{PK: "o1", RK: "l2", Data: map[string]any{"amount": "ten"}},
+					},
+					NextPageToken: "next",
+				},
+				{
+					Items: []database.ItemResult{
+						{PK: "o2", RK: "l1", Data: map[string]any{}},
+					},
+				},
+			},
+		},
+	}
+
+	var violations []Violation
+	results, err := Tables(context.Background(), scanner, []config.TableConfig{ordersTable()}, func(v Violation) {
+		violations = append(violations, v)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Checked != 3 || results[0].Violations != 2 || results[0].Missing {
+		t.Fatalf("unexpected results: %#v", results)
+	}
+	if len(violations) != 2 {
+		t.Fatalf("expected 2 violations, got %d", len(violations))
+	}
+	if got := violations[0].Keys(); got != `orderId="o1" lineId="l2"` {
+		t.Fatalf("unexpected keys: %s", got)
+	}
+	if !strings.Contains(violations[0].String(), "at '/amount'") {
+		t.Fatalf("expected validation path in violation, got: %s", violations[0].String())
+	}
+	if violations[1].PK != "o2" {
+		t.Fatalf("expected second violation for o2, got %q", violations[1].PK)
+	}
+}
+
+func TestTablesSkipsMissingTable(t *testing.T) {
+	scanner := &fakeScanner{calls: map[string]int{}, pages: map[string][]*database.ListResult{}}
+
+	results, err := Tables(context.Background(), scanner, []config.TableConfig{ordersTable()}, func(Violation) {
+		t.Fatal("unexpected violation")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || !results[0].Missing {
+		t.Fatalf("expected missing table result, got %#v", results)
+	}
+}
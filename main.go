@@ -2,24 +2,38 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/certs"
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/config"
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/database"
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/handler"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/health"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/logging"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/metrics"
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/middleware"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/ratelimit"
 	"github.com/UnitVectorY-Labs/itemservicecentral/internal/schema"
 	swaggerdoc "github.com/UnitVectorY-Labs/itemservicecentral/internal/swagger"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/tracing"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/transform"
+	"github.com/UnitVectorY-Labs/itemservicecentral/internal/verify"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Version is the application version, injected at build time via ldflags
@@ -35,6 +49,9 @@ func main() {
 		}
 	}
 
+	// Log as text at info level until a command loads the config.
+	logging.Setup(config.LoggingConfig{})
+
 	if len(os.Args) < 2 {
 		// Default to api command
 		os.Args = append(os.Args, "api")
@@ -47,8 +64,12 @@ func main() {
 		runValidate()
 	case "migrate":
 		runMigrate()
+	case "verify":
+		runVerify()
 	case "swagger":
 		runSwagger()
+	case "apikey":
+		runAPIKey()
 	case "version":
 		fmt.Println(Version)
 	default:
@@ -62,11 +83,35 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  api       Start the API server")
 	fmt.Fprintln(os.Stderr, "  validate  Validate the configuration file")
 	fmt.Fprintln(os.Stderr, "  migrate   Run database migrations")
+	fmt.Fprintln(os.Stderr, "  verify    Validate stored items against the configured schemas")
 	fmt.Fprintln(os.Stderr, "  swagger   Generate table OpenAPI YAML")
+	fmt.Fprintln(os.Stderr, "  apikey    Generate an API key and its hash")
 	fmt.Fprintln(os.Stderr, "  version   Print version")
 	os.Exit(1)
 }
 
+// fatal logs msg at error level with the given attributes and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// loadConfig loads and validates the config file, exiting on errors, and
+// applies its logging settings.
+func loadConfig(path string) *config.Config {
+	cfg, err := config.Load(path)
+	if err != nil {
+		fatal("failed to load config", "error", err)
+	}
+	if err := config.Validate(cfg); err != nil {
+		fatal("invalid config", "error", err)
+	}
+	if err := logging.Setup(cfg.Logging); err != nil {
+		fatal("invalid logging config", "error", err)
+	}
+	return cfg
+}
+
 // envOrDefault returns the environment variable value if set and the flag is at its default,
 // otherwise returns the flag value.
 func envOrDefault(flagVal, flagDefault, envVar string) string {
@@ -76,6 +121,19 @@ func envOrDefault(flagVal, flagDefault, envVar string) string {
 	return flagVal
 }
 
+// parsePortFlag parses a port given by flag or environment, returning 0 when
+// none was given.
+func parsePortFlag(value, name string) int {
+	if value == "" {
+		return 0
+	}
+	p, err := strconv.Atoi(value)
+	if err != nil {
+		fatal("invalid "+name, "error", err)
+	}
+	return p
+}
+
 func flagProvided(fs *flag.FlagSet, name string) bool {
 	provided := false
 	fs.Visit(func(f *flag.Flag) {
@@ -86,159 +144,412 @@ func flagProvided(fs *flag.FlagSet, name string) bool {
 	return provided
 }
 
-func resolveSkipConfigValidation(fs *flag.FlagSet, flagValue bool) bool {
-	if flagProvided(fs, "skip-config-validation") {
+// resolveBoolFlag returns the flag value if it was provided on the command line,
+// otherwise the parsed environment variable, otherwise false.
+func resolveBoolFlag(fs *flag.FlagSet, name, envVar string, flagValue bool) bool {
+	if flagProvided(fs, name) {
 		return flagValue
 	}
 
-	envValue, envSet := os.LookupEnv("SKIP_CONFIG_VALIDATION")
+	envValue, envSet := os.LookupEnv(envVar)
 	if !envSet {
 		return false
 	}
 
 	parsed, err := strconv.ParseBool(strings.TrimSpace(envValue))
 	if err != nil {
-		log.Fatalf("invalid SKIP_CONFIG_VALIDATION value %q: %v", envValue, err)
+		fatal("invalid boolean environment variable", "name", envVar, "value", envValue, "error", err)
 	}
 	return parsed
 }
 
-func runAPI() {
-	fs := flag.NewFlagSet("api", flag.ExitOnError)
-	configPath := fs.String("config", "config.yaml", "Path to config file")
-	port := fs.String("port", "", "Server port")
-	dbHost := fs.String("db-host", "localhost", "Database host")
-	dbPort := fs.String("db-port", "5432", "Database port")
-	dbName := fs.String("db-name", "", "Database name")
-	dbUser := fs.String("db-user", "", "Database username")
-	dbPassword := fs.String("db-password", "", "Database password")
-	dbSSLMode := fs.String("db-sslmode", "disable", "SSL mode")
-	skipConfigValidationFlag := fs.Bool("skip-config-validation", false, "Skip configuration hash validation against database metadata")
-	fs.Parse(os.Args[2:])
+// dbFlags holds the PostgreSQL connection flags shared by commands that connect to the database.
+type dbFlags struct {
+	host     *string
+	port     *string
+	name     *string
+	user     *string
+	password *string
+	sslMode  *string
+	portInt  int
+}
 
-	*configPath = envOrDefault(*configPath, "config.yaml", "CONFIG")
-	*port = envOrDefault(*port, "", "PORT")
-	*dbHost = envOrDefault(*dbHost, "localhost", "DB_HOST")
-	*dbPort = envOrDefault(*dbPort, "5432", "DB_PORT")
-	*dbName = envOrDefault(*dbName, "", "DB_NAME")
-	*dbUser = envOrDefault(*dbUser, "", "DB_USER")
-	*dbPassword = envOrDefault(*dbPassword, "", "DB_PASSWORD")
-	*dbSSLMode = envOrDefault(*dbSSLMode, "disable", "DB_SSLMODE")
+func registerDBFlags(fs *flag.FlagSet) *dbFlags {
+	return &dbFlags{
+		host:     fs.String("db-host", "localhost", "Database host"),
+		port:     fs.String("db-port", "5432", "Database port"),
+		name:     fs.String("db-name", "", "Database name"),
+		user:     fs.String("db-user", "", "Database username"),
+		password: fs.String("db-password", "", "Database password"),
+		sslMode:  fs.String("db-sslmode", "disable", "SSL mode"),
+	}
+}
+
+// resolve applies environment variable fallbacks and checks required values.
+func (f *dbFlags) resolve() {
+	*f.host = envOrDefault(*f.host, "localhost", "DB_HOST")
+	*f.port = envOrDefault(*f.port, "5432", "DB_PORT")
+	*f.name = envOrDefault(*f.name, "", "DB_NAME")
+	*f.user = envOrDefault(*f.user, "", "DB_USER")
+	*f.password = envOrDefault(*f.password, "", "DB_PASSWORD")
+	*f.sslMode = envOrDefault(*f.sslMode, "disable", "DB_SSLMODE")
 
-	if *dbName == "" {
-		log.Fatal("database name is required: set -db-name or DB_NAME")
+	if *f.name == "" {
+		fatal("database name is required: set -db-name or DB_NAME")
 	}
-	if *dbUser == "" {
-		log.Fatal("database user is required: set -db-user or DB_USER")
+	if *f.user == "" {
+		fatal("database user is required: set -db-user or DB_USER")
 	}
-	if *dbPassword == "" {
-		log.Fatal("database password is required: set -db-password or DB_PASSWORD")
+	if *f.password == "" {
+		fatal("database password is required: set -db-password or DB_PASSWORD")
 	}
 
-	dbPortInt, err := strconv.Atoi(*dbPort)
+	portInt, err := strconv.Atoi(*f.port)
 	if err != nil {
-		log.Fatalf("invalid db-port: %v", err)
+		fatal("invalid db-port", "error", err)
 	}
+	f.portInt = portInt
+}
 
-	cfg, err := config.Load(*configPath)
+func (f *dbFlags) connect() *sql.DB {
+	db, err := database.Connect(*f.host, f.portInt, *f.name, *f.user, *f.password, *f.sslMode)
 	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
+		fatal("failed to connect to database", "error", err)
 	}
+	return db
+}
 
-	if err := config.Validate(cfg); err != nil {
-		log.Fatalf("invalid config: %v", err)
-	}
+func runAPI() {
+	fs := flag.NewFlagSet("api", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config file")
+	port := fs.String("port", "", "Server port")
+	adminPort := fs.String("admin-port", "", "Admin port serving /metrics and /status (overrides server.admin.port)")
+	dbf := registerDBFlags(fs)
+	skipConfigValidationFlag := fs.Bool("skip-config-validation", false, "Skip configuration hash validation against database metadata")
+	autoMigrateFlag := fs.Bool("auto-migrate", false, "Apply additive migrations before starting (never drops tables or indexes)")
+	lockTimeout := fs.Duration("lock-timeout", time.Minute, "With -auto-migrate, how long to wait for a concurrent migration to finish (0 waits indefinitely)")
+	configPollInterval := fs.Duration("config-poll-interval", 10*time.Second, "How often to check the config file for changes to reload (0 reloads on SIGHUP only)")
+	fs.Parse(os.Args[2:])
 
-	// Override port from flag/env if set
-	if *port != "" {
-		p, err := strconv.Atoi(*port)
-		if err != nil {
-			log.Fatalf("invalid port: %v", err)
+	*configPath = envOrDefault(*configPath, "config.yaml", "CONFIG")
+	*port = envOrDefault(*port, "", "PORT")
+	*adminPort = envOrDefault(*adminPort, "", "ADMIN_PORT")
+	dbf.resolve()
+
+	portOverride := parsePortFlag(*port, "port")
+	adminPortOverride := parsePortFlag(*adminPort, "admin-port")
+	// applyOverrides sets the ports given by flag or environment, at startup
+	// and on each reload.
+	applyOverrides := func(c *config.Config) {
+		if portOverride != 0 {
+			c.Server.Port = portOverride
+		}
+		if adminPortOverride != 0 {
+			c.Server.Admin.Port = adminPortOverride
 		}
-		cfg.Server.Port = p
 	}
 
-	db, err := database.Connect(*dbHost, dbPortInt, *dbName, *dbUser, *dbPassword, *dbSSLMode)
-	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+	cfg := loadConfig(*configPath)
+	applyOverrides(cfg)
+	if cfg.Server.Admin.Port != 0 && cfg.Server.Admin.Port == cfg.Server.Port {
+		fatal("admin port must differ from the server port", "port", cfg.Server.Admin.Port)
 	}
+
+	db := dbf.connect()
 	defer db.Close()
 
-	skipConfigValidation := resolveSkipConfigValidation(fs, *skipConfigValidationFlag)
+	autoMigrate := resolveBoolFlag(fs, "auto-migrate", "AUTO_MIGRATE", *autoMigrateFlag)
+	skipConfigValidation := resolveBoolFlag(fs, "skip-config-validation", "SKIP_CONFIG_VALIDATION", *skipConfigValidationFlag)
 	if skipConfigValidation {
-		log.Printf("WARNING: skipping config hash validation; database/config mismatch checks are disabled")
+		slog.Warn("skipping config hash validation; database/config mismatch checks are disabled")
+	}
+
+	// checkSchema checks that the schema's tables match the config.
+	checkSchema := func(ctx context.Context, schema string) error {
+		if skipConfigValidation {
+			return nil
+		}
+		return database.ValidateTablesConfigHash(ctx, db, schema, cfg.Tables)
+	}
+
+	var tenantMw *middleware.TenantMiddleware
+	if cfg.Database.TenantClaim != "" {
+		// Tenant schemas are provisioned with migrate -tenant and checked on
+		// each tenant's first request; requests never create or migrate them.
+		if autoMigrate {
+			slog.Warn("-auto-migrate does not apply in schema-per-tenant mode; migrate tenants with migrate -tenant")
+		}
+		tenantMw = middleware.NewTenantMiddleware(cfg.Database.TenantClaim, cfg.Database.TenantSchema, func(ctx context.Context, schema string) error {
+			if err := database.RequireSchema(ctx, db, schema); err != nil {
+				return err
+			}
+			return checkSchema(ctx, schema)
+		})
 	} else {
-		if err := database.ValidateTablesConfigHash(db, cfg.Tables); err != nil {
-			log.Fatalf("configuration validation failed: %v", err)
+		if autoMigrate {
+			if err := database.Migrate(db, cfg.Tables, database.MigrateOptions{
+				AdditiveOnly: true,
+				ToolVersion:  Version,
+				LockTimeout:  *lockTimeout,
+				Schema:       cfg.Database.Schema,
+			}); err != nil {
+				fatal("auto-migrate failed", "error", err)
+			}
+		}
+		if err := checkSchema(context.Background(), cfg.Database.Schema); err != nil {
+			fatal("configuration validation failed", "error", err)
 		}
 	}
 
-	store := database.NewStore(db)
+	store := database.NewStoreWithSchema(db, cfg.Database.Schema)
+	store.SetSlowQueryThreshold(cfg.Logging.SlowQueryThreshold)
 
-	h, err := handler.NewWithOptions(store, cfg.Tables, handler.Options{
-		SwaggerEnabled: cfg.Server.Swagger.Enabled,
-		JWTEnabled:     cfg.Server.JWT.Enabled,
+	jwtMw, err := middleware.NewJWTMiddleware(cfg.Server.JWT.Enabled, middleware.JWTOptions{
+		JWKSURL:    cfg.Server.JWT.JWKSUrl,
+		Issuer:     cfg.Server.JWT.Issuer,
+		Audience:   cfg.Server.JWT.Audience,
+		Algorithms: cfg.Server.JWT.Algorithms,
+		HMACSecret: []byte(os.Getenv("JWT_HMAC_SECRET")),
 	})
 	if err != nil {
-		log.Fatalf("failed to create handler: %v", err)
+		fatal("failed to create JWT middleware", "error", err)
 	}
+	if cfg.Server.APIKeys.Enabled {
+		jwtMw.SetAPIKeys(newAPIKeyAuthenticator(cfg.Server.APIKeys, store))
+	}
+	jwtMw.SetClientCertIdentity(cfg.Server.TLS.ClientCertIdentity)
 
-	jwtMw, err := middleware.NewJWTMiddleware(
-		cfg.Server.JWT.Enabled,
-		cfg.Server.JWT.JWKSUrl,
-		cfg.Server.JWT.Issuer,
-		cfg.Server.JWT.Audience,
-	)
+	// Readiness needs the database, the migrated table structure (checked
+	// per tenant on first request in schema-per-tenant mode), and the JWKS
+	// keys tokens are verified with.
+	ready := health.NewChecker()
+	ready.Add("database", db.PingContext)
+	if tenantMw == nil && !skipConfigValidation {
+		ready.Add("configHash", func(ctx context.Context) error {
+			return database.ValidateTablesConfigHash(ctx, db, cfg.Database.Schema, cfg.Tables)
+		})
+	}
+	if cfg.Server.JWT.Enabled {
+		ready.Add("jwks", jwtMw.CheckKeys)
+	}
+
+	// buildMux builds the routes for c. A config reload replaces them all at
+	// once through router; rate limit buckets and scan slots carry over
+	// through limits.
+	limits := handler.NewLimits()
+	buildMux := func(c *config.Config) (*http.ServeMux, error) {
+		h, err := handler.NewWithOptions(store, c.Tables, handler.Options{
+			SwaggerEnabled: c.Server.Swagger.Enabled,
+			JWTEnabled:     c.Server.JWT.Enabled,
+			RolesClaim:     c.Server.JWT.RolesClaim,
+			AdminAccess:    c.Server.Admin.Access,
+			Explain:        c.Server.Admin.Explain,
+
+			MaxPageSize:        c.Server.MaxPageSize,
+			MaxConcurrentScans: c.Server.RateLimit.MaxConcurrentScans,
+			Limits:             limits,
+		})
+		if err != nil {
+			return nil, err
+		}
+		mux := http.NewServeMux()
+		h.SetupRoutes(mux)
+		ready.Register(mux)
+		return mux, nil
+	}
+	mux, err := buildMux(cfg)
 	if err != nil {
-		log.Fatalf("failed to create JWT middleware: %v", err)
+		fatal("failed to create handler", "error", err)
 	}
+	router := &apiRouter{}
+	router.mux.Store(mux)
 
-	mux := http.NewServeMux()
-	h.SetupRoutes(mux)
+	var tableHandler http.Handler = router
+	if tenantMw != nil {
+		tableHandler = tenantMw.Handler(router)
+	}
 
-	protectedHandler := jwtMw.Handler(mux)
-	apiHandler := protectedHandler
-	if cfg.Server.Swagger.Enabled {
-		apiHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if handler.IsSwaggerRequestPath(r.URL.Path) {
-				mux.ServeHTTP(w, r)
-				return
+	rateLimitMw := middleware.NewRateLimitMiddleware(
+		ratelimit.FromConfig(cfg.Server.RateLimit.Global, nil),
+		ratelimit.FromConfig(cfg.Server.RateLimit.PerClient, cfg.Server.RateLimit.Clients),
+	)
+	protectedHandler := jwtMw.Handler(rateLimitMw.Handler(tableHandler))
+	// Probes and swagger bypass authentication and rate limits; swagger
+	// paths are only routed while swagger is enabled.
+	var apiHandler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if health.IsProbePath(r.URL.Path) || handler.IsSwaggerRequestPath(r.URL.Path) {
+			router.ServeHTTP(w, r)
+			return
+		}
+		protectedHandler.ServeHTTP(w, r)
+	})
+
+	// The admin listener serves metrics and the status report; every API
+	// request is counted.
+	var adminSrv *http.Server
+	if cfg.Server.Admin.Port != 0 {
+		metrics.RegisterDBStats(db)
+		apiHandler = metrics.Instrument(router, apiHandler)
+
+		configHash, err := database.TablesConfigHash(cfg.Tables)
+		if err != nil {
+			fatal("failed to compute config hash", "error", err)
+		}
+		status := health.StatusOptions{
+			Version:    Version,
+			ConfigHash: configHash,
+		}
+		for _, t := range cfg.Tables {
+			status.Tables = append(status.Tables, t.Name)
+		}
+		if tenantMw == nil {
+			status.Migration = func(ctx context.Context) (*database.MigrationStatus, error) {
+				return database.ReadMigrationStatus(ctx, db, cfg.Database.Schema)
 			}
-			protectedHandler.ServeHTTP(w, r)
-		})
+		}
+
+		adminMux := http.NewServeMux()
+		adminMux.Handle("GET /metrics", promhttp.Handler())
+		adminMux.Handle("GET /status", health.StatusHandler(status))
+		adminSrv = &http.Server{
+			Addr:    ":" + strconv.Itoa(cfg.Server.Admin.Port),
+			Handler: adminMux,
+		}
+	}
+
+	// Tracing wraps everything else so authentication and rate limiting are
+	// part of each request's span.
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Server.Tracing)
+	if err != nil {
+		fatal("failed to set up tracing", "error", err)
+	}
+	if shutdownTracing != nil {
+		apiHandler = tracing.Middleware(router, apiHandler)
 	}
 
+	// Every request gets an ID and an access log line.
+	apiHandler = logging.Middleware(router, apiHandler)
+
 	srv := &http.Server{
 		Addr:    ":" + strconv.Itoa(cfg.Server.Port),
 		Handler: apiHandler,
 	}
 
-	log.Printf("itemservicecentral %s starting on port %d", Version, cfg.Server.Port)
-	log.Printf("loaded %d table(s)", len(cfg.Tables))
+	var tlsReloader *certs.Reloader
+	if cfg.Server.TLS.Enabled() {
+		tlsReloader, err = certs.NewReloader(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile, cfg.Server.TLS.ClientCAFile)
+		if err != nil {
+			fatal("failed to load TLS certificate", "error", err)
+		}
+		srv.TLSConfig = tlsReloader.TLSConfig(certs.ClientAuthType(cfg.Server.TLS.ClientAuth))
+	}
+
+	slog.Info("itemservicecentral starting", "version", Version, "port", cfg.Server.Port, "tables", len(cfg.Tables))
 	if cfg.Server.Swagger.Enabled {
-		log.Printf("swagger endpoints enabled")
+		slog.Info("swagger endpoints enabled")
+	}
+	if tlsReloader != nil {
+		slog.Info("serving HTTPS", "clientAuth", cfg.Server.TLS.ClientAuth)
+	}
+	if adminSrv != nil {
+		slog.Info("admin endpoints enabled", "port", cfg.Server.Admin.Port)
+	}
+	if shutdownTracing != nil {
+		slog.Info("tracing enabled", "exporter", cfg.Server.Tracing.Exporter)
+	}
+	if tenantMw != nil {
+		slog.Info("schema-per-tenant mode", "tenantClaim", cfg.Database.TenantClaim, "schemas", cfg.Database.Schema+"_<tenant>")
 	}
 
 	// Graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	jwtMw.StartKeyRefresh(ctx)
+
+	// reloadConfig applies the settings that can change while serving. Each
+	// reload is checked against the config currently being served.
+	var live atomic.Pointer[config.Config]
+	live.Store(cfg)
+	reloadConfig := func() {
+		next, err := config.Load(*configPath)
+		if err == nil {
+			err = config.Validate(next)
+		}
+		if err != nil {
+			slog.Error("config reload failed, keeping the current config", "error", err)
+			return
+		}
+		applyOverrides(next)
+		restart, err := config.CheckReload(live.Load(), next)
+		if err != nil {
+			slog.Error("config reload refused, keeping the current config", "error", err)
+			return
+		}
+		logger, err := logging.New(os.Stderr, next.Logging.Level, next.Logging.Format)
+		if err != nil {
+			slog.Error("config reload failed, keeping the current config", "error", err)
+			return
+		}
+		served := config.Served(live.Load(), next)
+		mux, err := buildMux(served)
+		if err != nil {
+			slog.Error("config reload failed, keeping the current config", "error", err)
+			return
+		}
+
+		slog.SetDefault(logger)
+		router.mux.Store(mux)
+		live.Store(served)
+		jwtMw.SetExpectedClaims(served.Server.JWT.Issuer, served.Server.JWT.Audience)
+		store.SetSlowQueryThreshold(served.Logging.SlowQueryThreshold)
+		slog.Info("config reloaded", "tables", len(served.Tables))
+		if len(restart) > 0 {
+			slog.Warn("changed settings take effect after a restart", "settings", restart)
+		}
+	}
+	go watchConfig(ctx, *configPath, *configPollInterval, reloadConfig)
+
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
+		var err error
+		if tlsReloader != nil {
+			go tlsReloader.Run(ctx, 30*time.Second)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("server error", "error", err)
 		}
 	}()
+	if adminSrv != nil {
+		go func() {
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("admin server error", "error", err)
+			}
+		}()
+	}
 
 	<-ctx.Done()
-	log.Println("shutting down...")
+	slog.Info("shutting down")
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("shutdown error: %v", err)
+		fatal("shutdown error", "error", err)
 	}
-	log.Println("server stopped")
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(shutdownCtx); err != nil {
+			fatal("admin shutdown error", "error", err)
+		}
+	}
+	if shutdownTracing != nil {
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			slog.Error("tracing shutdown error", "error", err)
+		}
+	}
+	slog.Info("server stopped")
 }
 
 func runValidate() {
@@ -287,29 +598,22 @@ func runSwagger() {
 	*configPath = envOrDefault(*configPath, "config.yaml", "CONFIG")
 
 	if strings.TrimSpace(*tableName) == "" {
-		log.Fatal("table name is required: set -table")
-	}
-
-	cfg, err := config.Load(*configPath)
-	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
+		fatal("table name is required: set -table")
 	}
 
-	if err := config.Validate(cfg); err != nil {
-		log.Fatalf("invalid config: %v", err)
-	}
+	cfg := loadConfig(*configPath)
 
 	table, ok := swaggerdoc.FindTable(cfg.Tables, *tableName)
 	if !ok {
-		log.Fatalf("table %q is not configured", *tableName)
+		fatal("table is not configured", "table", *tableName)
 	}
 	if _, err := schema.Compile(table.Schema); err != nil {
-		log.Fatalf("table %q: schema error: %v", table.Name, err)
+		fatal("schema error", "table", table.Name, "error", err)
 	}
 
 	doc, err := swaggerdoc.GenerateTableYAML(table, cfg.Server.JWT.Enabled)
 	if err != nil {
-		log.Fatalf("failed to generate OpenAPI for table %q: %v", *tableName, err)
+		fatal("failed to generate OpenAPI", "table", *tableName, "error", err)
 	}
 
 	if *outputPath == "" {
@@ -318,64 +622,328 @@ func runSwagger() {
 	}
 
 	if err := os.WriteFile(*outputPath, doc, 0644); err != nil {
-		log.Fatalf("failed to write OpenAPI file: %v", err)
+		fatal("failed to write OpenAPI file", "error", err)
+	}
+}
+
+// newAPIKeyAuthenticator builds the X-API-Key authenticator from the keys in
+// the config and, when enabled, the _api_keys table.
+func newAPIKeyAuthenticator(c config.APIKeysConfig, store *database.Store) *middleware.APIKeyAuthenticator {
+	keys := make(map[string]middleware.APIKey, len(c.Keys))
+	for _, k := range c.Keys {
+		keys[k.Hash] = middleware.APIKey{Name: k.Name, Scopes: k.Scopes, Claims: k.Claims}
+	}
+
+	var lookup middleware.APIKeyLookup
+	if c.Table {
+		lookup = func(ctx context.Context, hash string) (*middleware.APIKey, error) {
+			k, err := store.LookupAPIKey(ctx, hash)
+			if err != nil || k == nil {
+				return nil, err
+			}
+			return &middleware.APIKey{Name: k.Name, Scopes: k.Scopes, Claims: k.Claims}, nil
+		}
+	}
+	return middleware.NewAPIKeyAuthenticator(keys, lookup)
+}
+
+func runAPIKey() {
+	fs := flag.NewFlagSet("apikey", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config file (read for database.schema with -store)")
+	name := fs.String("name", "", "Key name, used in the sub claim as apikey:<name>")
+	scopes := fs.String("scopes", "", "Comma-separated scopes granted to the key")
+	claims := fs.String("claims", "", "Comma-separated name=value claims added for the key")
+	expires := fs.Duration("expires", 0, "With -store, how long the key is valid (0 never expires)")
+	store := fs.Bool("store", false, "Store the key hash in the _api_keys table")
+	dbf := registerDBFlags(fs)
+	fs.Parse(os.Args[2:])
+
+	if strings.TrimSpace(*name) == "" {
+		fatal("key name is required: set -name")
+	}
+	if *expires > 0 && !*store {
+		fatal("-expires requires -store; keys listed in the config do not expire")
+	}
+
+	claimMap := make(map[string]string)
+	for _, pair := range splitList(*claims) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" || k == "scope" || k == "scp" {
+			fatal("invalid -claims entry: use name=value and -scopes for scopes", "entry", pair)
+		}
+		claimMap[k] = v
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		fatal("failed to generate key", "error", err)
+	}
+	key := "isc_" + base64.RawURLEncoding.EncodeToString(secret)
+	hash := middleware.HashAPIKey(key)
+
+	if *store {
+		*configPath = envOrDefault(*configPath, "config.yaml", "CONFIG")
+		dbf.resolve()
+
+		cfg := loadConfig(*configPath)
+
+		db := dbf.connect()
+		defer db.Close()
+
+		record := database.APIKey{Name: *name, Scopes: splitList(*scopes), Claims: claimMap}
+		if *expires > 0 {
+			expiresAt := time.Now().Add(*expires)
+			record.ExpiresAt = &expiresAt
+		}
+		if err := database.EnsureAPIKeysTable(db, cfg.Database.Schema); err != nil {
+			fatal("failed to prepare _api_keys table", "error", err)
+		}
+		if err := database.InsertAPIKey(db, cfg.Database.Schema, hash, record); err != nil {
+			fatal("failed to store API key", "error", err)
+		}
+	}
+
+	fmt.Printf("Key:  %s\n", key)
+	fmt.Printf("Hash: %s\n", hash)
+	if !*store {
+		fmt.Println("Add the hash to server.apiKeys.keys, or rerun with -store to save it in _api_keys.")
 	}
 }
 
 func runMigrate() {
 	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
 	configPath := fs.String("config", "config.yaml", "Path to config file")
-	dbHost := fs.String("db-host", "localhost", "Database host")
-	dbPort := fs.String("db-port", "5432", "Database port")
-	dbName := fs.String("db-name", "", "Database name")
-	dbUser := fs.String("db-user", "", "Database username")
-	dbPassword := fs.String("db-password", "", "Database password")
-	dbSSLMode := fs.String("db-sslmode", "disable", "SSL mode")
+	dbf := registerDBFlags(fs)
 	cleanup := fs.Bool("cleanup", false, "Delete tables and indexes not in config")
 	dryRun := fs.Bool("dry-run", false, "Print changes without applying them")
+	concurrentIndexes := fs.Bool("concurrent-indexes", false, "Build new indexes with CREATE INDEX CONCURRENTLY outside the migration transaction")
+	rebuildDriftedIndexes := fs.Bool("rebuild-drifted-indexes", false, "Drop and recreate indexes whose definition no longer matches config")
+	checkData := fs.Bool("check-data", false, "Validate existing rows against the configured schemas before migrating")
+	failOnInvalidData := fs.Bool("fail-on-invalid-data", false, "With -check-data, abort the migration when any row fails validation")
+	migrationsDir := fs.String("migrations-dir", "migrations", "Directory of versioned data transformation files")
+	rekey := fs.String("rekey", "", "Comma-separated tables to copy to new key fields when their primaryKey or rangeKey changed")
+	plan := fs.Bool("plan", false, "Print the planned create/drop actions without applying them")
+	output := fs.String("output", "text", "Plan output format: text or json")
+	lockTimeout := fs.Duration("lock-timeout", time.Minute, "How long to wait for a concurrent migration to finish (0 waits indefinitely)")
+	tenants := fs.String("tenant", "", "Comma-separated tenants to migrate in schema-per-tenant mode")
 	fs.Parse(os.Args[2:])
 
+	if *output != "text" && *output != "json" {
+		fatal("invalid -output: must be text or json", "output", *output)
+	}
+	if *output == "json" && !*plan {
+		fatal("-output json requires -plan")
+	}
+	if *plan && *checkData {
+		fatal("-check-data cannot be combined with -plan")
+	}
+
 	*configPath = envOrDefault(*configPath, "config.yaml", "CONFIG")
-	*dbHost = envOrDefault(*dbHost, "localhost", "DB_HOST")
-	*dbPort = envOrDefault(*dbPort, "5432", "DB_PORT")
-	*dbName = envOrDefault(*dbName, "", "DB_NAME")
-	*dbUser = envOrDefault(*dbUser, "", "DB_USER")
-	*dbPassword = envOrDefault(*dbPassword, "", "DB_PASSWORD")
-	*dbSSLMode = envOrDefault(*dbSSLMode, "disable", "DB_SSLMODE")
+	*migrationsDir = envOrDefault(*migrationsDir, "migrations", "MIGRATIONS_DIR")
+	dbf.resolve()
 
-	if *dbName == "" {
-		log.Fatal("database name is required: set -db-name or DB_NAME")
+	cfg := loadConfig(*configPath)
+
+	transforms := loadTransforms(fs, *migrationsDir, cfg.Tables)
+
+	rekeyTables := splitList(*rekey)
+	for _, name := range rekeyTables {
+		if _, ok := swaggerdoc.FindTable(cfg.Tables, name); !ok {
+			fatal("-rekey table is not configured", "table", name)
+		}
 	}
-	if *dbUser == "" {
-		log.Fatal("database user is required: set -db-user or DB_USER")
+
+	schemas := targetSchemas(cfg, *tenants)
+	if *plan && *output == "json" && len(schemas) > 1 {
+		fatal("-output json plans a single schema; pass one -tenant")
 	}
-	if *dbPassword == "" {
-		log.Fatal("database password is required: set -db-password or DB_PASSWORD")
+
+	db := dbf.connect()
+	defer db.Close()
+
+	opts := database.MigrateOptions{
+		Cleanup:               *cleanup,
+		DryRun:                *dryRun,
+		ConcurrentIndexes:     *concurrentIndexes,
+		RebuildDriftedIndexes: *rebuildDriftedIndexes,
+		Transforms:            transforms,
+		Rekey:                 rekeyTables,
+		ToolVersion:           Version,
+		LockTimeout:           *lockTimeout,
 	}
 
-	dbPortInt, err := strconv.Atoi(*dbPort)
-	if err != nil {
-		log.Fatalf("invalid db-port: %v", err)
+	for _, schema := range schemas {
+		if schema != "" {
+			slog.Info("using schema", "schema", schema)
+		}
+		opts.Schema = schema
+
+		if *checkData {
+			violations := verifyData(db, schema, cfg.Tables)
+			if violations > 0 && *failOnInvalidData {
+				fatal("items do not match the configured schemas; migration aborted", "violations", violations)
+			}
+		}
+
+		if *plan {
+			p, err := database.PlanMigration(db, cfg.Tables, opts)
+			if err != nil {
+				fatal("failed to plan migrations", "error", err)
+			}
+			printPlan(p, *output)
+			continue
+		}
+
+		if err := database.Migrate(db, cfg.Tables, opts); err != nil {
+			fatal("failed to run migrations", "error", err)
+		}
 	}
 
-	cfg, err := config.Load(*configPath)
-	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
+	if !*plan {
+		fmt.Println("Migrations complete")
+	}
+}
+
+// targetSchemas returns the PostgreSQL schemas a command works on: the
+// configured schema, or one schema per listed tenant in schema-per-tenant mode.
+func targetSchemas(cfg *config.Config, tenants string) []string {
+	names := splitList(tenants)
+	if cfg.Database.TenantClaim == "" {
+		if len(names) > 0 {
+			fatal("-tenant requires database.tenantClaim in the config")
+		}
+		return []string{cfg.Database.Schema}
 	}
 
-	if err := config.Validate(cfg); err != nil {
-		log.Fatalf("invalid config: %v", err)
+	if len(names) == 0 {
+		fatal("schema-per-tenant mode requires -tenant")
+	}
+	schemas := make([]string, 0, len(names))
+	for _, name := range names {
+		schema, err := cfg.Database.TenantSchema(name)
+		if err != nil {
+			fatal("invalid -tenant", "error", err)
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas
+}
+
+// printPlan writes a migration plan to stdout as text or JSON.
+func printPlan(p *database.Plan, output string) {
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(p); err != nil {
+			fatal("failed to encode plan", "error", err)
+		}
+		return
+	}
+
+	destructive := 0
+	for _, a := range p.Actions {
+		marker := ""
+		if a.Destructive {
+			marker = " (destructive)"
+			destructive++
+		}
+		fmt.Printf("- %s%s\n", a.Description, marker)
+	}
+	fmt.Printf("Config hash: %s\n", p.ConfigHash)
+	fmt.Printf("Plan: %d action(s), %d destructive\n", len(p.Actions), destructive)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			out = append(out, trimmed)
+		}
+	}
+	return out
+}
+
+// loadTransforms reads and validates the data transformation files. A missing
+// directory is only an error when it was explicitly requested.
+func loadTransforms(fs *flag.FlagSet, dir string, tables []config.TableConfig) []transform.Migration {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if flagProvided(fs, "migrations-dir") || os.Getenv("MIGRATIONS_DIR") != "" {
+			fatal("migrations directory does not exist", "dir", dir)
+		}
+		return nil
 	}
 
-	db, err := database.Connect(*dbHost, dbPortInt, *dbName, *dbUser, *dbPassword, *dbSSLMode)
+	transforms, err := transform.Load(dir)
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		fatal("failed to load migrations", "error", err)
+	}
+	if err := transform.Validate(transforms, tables); err != nil {
+		fatal("invalid migrations", "error", err)
+	}
+	return transforms
+}
+
+func runVerify() {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config file")
+	tableName := fs.String("table", "", "Only verify this table (all tables when omitted)")
+	tenants := fs.String("tenant", "", "Comma-separated tenants to verify in schema-per-tenant mode")
+	dbf := registerDBFlags(fs)
+	fs.Parse(os.Args[2:])
+
+	*configPath = envOrDefault(*configPath, "config.yaml", "CONFIG")
+	dbf.resolve()
+
+	cfg := loadConfig(*configPath)
+
+	tables := cfg.Tables
+	if *tableName != "" {
+		table, ok := swaggerdoc.FindTable(cfg.Tables, *tableName)
+		if !ok {
+			fatal("table is not configured", "table", *tableName)
+		}
+		tables = []config.TableConfig{table}
 	}
+
+	schemas := targetSchemas(cfg, *tenants)
+
+	db := dbf.connect()
 	defer db.Close()
 
-	if err := database.Migrate(db, cfg.Tables, database.MigrateOptions{Cleanup: *cleanup, DryRun: *dryRun}); err != nil {
-		log.Fatalf("failed to run migrations: %v", err)
+	violations := 0
+	for _, schema := range schemas {
+		if schema != "" && len(schemas) > 1 {
+			fmt.Printf("schema %q\n", schema)
+		}
+		violations += verifyData(db, schema, tables)
 	}
+	if violations > 0 {
+		fmt.Fprintf(os.Stderr, "found %d item(s) that do not match the configured schemas\n", violations)
+		os.Exit(1)
+	}
+
+	fmt.Println("All items match the configured schemas")
+}
 
-	fmt.Println("Migrations complete")
+// verifyData streams every stored item through its table schema, printing each
+// violation and a per-table summary. It returns the total number of violations.
+func verifyData(db *sql.DB, schema string, tables []config.TableConfig) int {
+	results, err := verify.Tables(context.Background(), database.NewStoreWithSchema(db, schema), tables, func(v verify.Violation) {
+		fmt.Println(v.String())
+	})
+	if err != nil {
+		fatal("failed to verify data", "error", err)
+	}
+
+	total := 0
+	for _, r := range results {
+		if r.Missing {
+			fmt.Printf("table %q: not created yet, skipped\n", r.Table)
+			continue
+		}
+		fmt.Printf("table %q: checked %d item(s), %d violation(s)\n", r.Table, r.Checked, r.Violations)
+		total += r.Violations
+	}
+	return total
 }
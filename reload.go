@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// apiRouter serves requests with the most recently loaded routes, so a
+// config reload swaps them all at once.
+type apiRouter struct {
+	mux atomic.Pointer[http.ServeMux]
+}
+
+func (r *apiRouter) Handler(req *http.Request) (http.Handler, string) {
+	return r.mux.Load().Handler(req)
+}
+
+func (r *apiRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.Load().ServeHTTP(w, req)
+}
+
+// watchConfig calls reload on SIGHUP and, with a positive interval, when the
+// contents of the config file at path change, until ctx is done.
+func watchConfig(ctx context.Context, path string, interval time.Duration, reload func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	// Contents are compared rather than modification times, so saving the
+	// file unchanged does not reload it.
+	sum, err := fileSum(path)
+	if err != nil {
+		slog.Warn("reading config file failed", "error", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("SIGHUP received, reloading config")
+			if s, err := fileSum(path); err == nil {
+				sum = s
+			}
+			reload()
+		case <-tick:
+			s, err := fileSum(path)
+			if err != nil {
+				slog.Warn("reading config file failed", "error", err)
+				continue
+			}
+			if bytes.Equal(s, sum) {
+				continue
+			}
+			sum = s
+			slog.Info("config file changed, reloading config")
+			reload()
+		}
+	}
+}
+
+func fileSum(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := sha256.Sum256(data)
+	return s[:], nil
+}